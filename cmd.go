@@ -0,0 +1,238 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/pattern"
+	"github.com/hsluoyz/logdance/render"
+	"github.com/hsluoyz/logdance/util"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"crawl":   {"crawl [flags] <url>: crawl a site and write its page graph", runCrawl},
+		"ingest":  {"ingest [flags] <access.log|dir|glob>...: build the page graph of a site from its access logs, \"-\" reads the standard input", runIngest},
+		"tail":    {"tail [flags] <access.log>: follow a live access log and keep updating the page graph", runTail},
+		"serve":   {"serve [flags] [webgraph.json]: serve the visualisation of a page graph", runServe},
+		"convert": {"convert [flags] <webgraph.json>: read a saved page graph and write it in another format or pruned", runConvert},
+		"diff":    {"diff [flags] <old.json> <new.json>: compare two page graphs like two crawls or time windows", runDiff},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: logdance <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run \"logdance <command> -h\" for the flags of a command.")
+}
+
+// newFlagSet creates the flag set of a command with the flags shared by all
+// commands.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: logdance %s\n\nFlags:\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// newNormalizer creates a normalizer with the rules file at rules, if any.
+func newNormalizer(rules string) (*pattern.Normalizer, error) {
+	n := pattern.NewNormalizer()
+	if rules != "" {
+		rs, err := pattern.LoadRules(rules)
+		if err != nil {
+			return nil, err
+		}
+		n.SetRules(rs)
+	}
+	return n, nil
+}
+
+// hostLimits is a repeatable flag like "-host-parallel *.example.com=2".
+type hostLimits map[string]int
+
+func (hl hostLimits) String() string {
+	return fmt.Sprint(map[string]int(hl))
+}
+
+func (hl hostLimits) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i == -1 {
+		return fmt.Errorf("%q is not like host=N", value)
+	}
+
+	n, err := strconv.Atoi(value[i+1:])
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q is not like host=N", value)
+	}
+	hl[value[:i]] = n
+	return nil
+}
+
+// outputFlags are the flags of the graph output.
+type outputFlags struct {
+	path   *string
+	format *string
+	opts   render.Options
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := outputFlags{}
+	o.path = fs.String("o", "webgraph.json", "output path of the graph")
+	o.format = fs.String("out-format", "", fmt.Sprintf("output format, one of %v, by the extension of -o if empty", render.GetFormatNames()))
+	fs.IntVar(&o.opts.MaxNodes, "max-nodes", 0, "maximum number of the pages in the output, the most visited ones are kept, 0 means unlimited")
+	fs.IntVar(&o.opts.MaxEdges, "max-edges", 0, "maximum number of the links in the output, the heaviest ones are kept, 0 means unlimited")
+	fs.IntVar(&o.opts.MinWeight, "min-weight", 0, "prune the links of lower weights from the output")
+	return &o
+}
+
+// check checks the output format before the graph is built.
+func (o *outputFlags) check() error {
+	if *o.format == "" {
+		return nil
+	}
+	_, err := render.GetFormat(*o.format)
+	return err
+}
+
+// getFormat returns the output format after check().
+func (o *outputFlags) getFormat() *render.Format {
+	if *o.format == "" {
+		return render.GetFormatByPath(*o.path)
+	}
+	f, _ := render.GetFormat(*o.format)
+	return f
+}
+
+func (o *outputFlags) generate(g *graph.Graph) error {
+	return render.Generate(g, *o.path, *o.format, &o.opts)
+}
+
+func runCrawl(args []string) error {
+	fs := newFlagSet("crawl")
+	output := addOutputFlags(fs)
+	depth := fs.Int("depth", 0, "maximum crawl depth, 0 means unlimited")
+	parallel := fs.Int("parallel", 1, "number of concurrent requests per host")
+	hostParallel := hostLimits{}
+	fs.Var(hostParallel, "host-parallel", "number of concurrent requests to the hosts matching a glob, like *.example.com=2, can be repeated")
+	rules := fs.String("rules", "", "JSON file of the pattern rules")
+	infer := fs.Int("infer", 0, "infer a wildcard rule when this many sibling paths are seen, 0 disables the inference")
+	inferOut := fs.String("infer-out", "", "JSON rules file to save the rules in use, including the inferred ones")
+	serve := fs.String("serve", "", "address like :8080 to serve the visualisation of the graph while crawling and after")
+	state := fs.String("state", "", "file to save the crawl state periodically and on Ctrl-C, so the crawl can be resumed")
+	stateInterval := fs.Duration("state-interval", time.Minute, "interval to save the crawl state, 0 saves it only on Ctrl-C and at the end")
+	resume := fs.Bool("resume", false, "resume the crawl saved in the -state file")
+	logFile := fs.String("log", "page.log", "log file, empty to disable logging")
+	fs.IntVar(&verbosity, "v", 1, "verbosity: 0 quiet, 1 pages, 2 pages, redirections and links")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := output.check(); err != nil {
+		return err
+	}
+	if *resume && *state == "" {
+		return fmt.Errorf("-resume needs the -state file")
+	}
+	if err := util.SetLogFile(*logFile); err != nil {
+		return err
+	}
+	n, err := newNormalizer(*rules)
+	if err != nil {
+		return err
+	}
+
+	var in *pattern.Inferrer
+	if *infer > 0 {
+		in = pattern.NewInferrer(*infer)
+		n.SetInferrer(in)
+	}
+
+	opts := crawlOptions{}
+	opts.maxDepth = *depth
+	opts.parallel = *parallel
+	opts.hostParallel = hostParallel
+	opts.normalizer = n
+	opts.statePath = *state
+	opts.stateInterval = *stateInterval
+	opts.resume = *resume
+	if *serve != "" {
+		if opts.server, err = startServer(*serve); err != nil {
+			return err
+		}
+	}
+	g, err := crawl(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+
+	if in != nil && verbosity >= 1 {
+		for _, r := range in.Rules() {
+			fmt.Printf("Inferred rule: %s\n", r.Name)
+		}
+	}
+	if *inferOut != "" {
+		domain, err := pattern.GetFullDomainName(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		rs := n.ExportRules(domain)
+		if err := pattern.SaveRules(rs, *inferOut); err != nil {
+			return err
+		}
+	}
+	if err := output.generate(g); err != nil {
+		return err
+	}
+
+	if opts.server != nil {
+		if verbosity >= 1 {
+			fmt.Println("Crawl done, still serving, Ctrl-C to stop")
+		}
+		select {}
+	}
+	return nil
+}
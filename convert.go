@@ -0,0 +1,52 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hsluoyz/logdance/render"
+)
+
+func runConvert(args []string) error {
+	fs := newFlagSet("convert")
+	output := addOutputFlags(fs)
+	inFormat := fs.String("in-format", "", fmt.Sprintf("input format, one of %v, by the extension of the input if empty", render.GetReadableFormatNames()))
+	fs.IntVar(&verbosity, "v", 1, "verbosity: 0 quiet, 1 the numbers of the pages and links")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := output.check(); err != nil {
+		return err
+	}
+
+	g, err := render.Load(fs.Arg(0), *inFormat)
+	if err != nil {
+		return err
+	}
+	if verbosity >= 1 {
+		links := 0
+		pages := g.Pages()
+		for _, page := range pages {
+			links += len(page.Links)
+		}
+		fmt.Printf("%d pages, %d links\n", len(pages), links)
+	}
+	return output.generate(g)
+}
@@ -0,0 +1,340 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly"
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/pattern"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// Crawler crawls a site into its own page graph.
+type Crawler struct {
+	Graph      *graph.Graph
+	Normalizer *pattern.Normalizer
+
+	// MaxDepth is the maximum crawl depth, 0 means unlimited.
+	MaxDepth int
+	// Verbosity controls the output to Out: 0 prints nothing, 1 prints the
+	// new pages and 2 also prints the redirections and links.
+	Verbosity int
+	Out       io.Writer
+
+	// Parallel is the number of the concurrent requests to a host, the pages
+	// are crawled one by one if it is 0 or 1.
+	Parallel int
+	// HostParallel overrides Parallel for the hosts matching the globs like
+	// "*.example.com".
+	HostParallel map[string]int
+
+	// visits maps the URL to visit to its path and pattern, they are moved
+	// to requests by the request ID once the request starts.
+	visits   sync.Map
+	requests sync.Map
+
+	// stateMu makes a link and its pending page one change of the state, see
+	// SaveState().
+	stateMu sync.RWMutex
+	target  string
+	// pending are the visits found but not crawled yet by their URLs, and
+	// visited are the URLs crawled.
+	pending   map[string]*visit
+	visited   map[string]bool
+	pendingMu sync.Mutex
+}
+
+// visit is the requested URL and path of a page, its pattern and its crawl
+// depth.
+type visit struct {
+	url     string
+	path    string
+	pattern string
+	depth   int
+
+	// links and hit are what the crawl of the page has added to the graph,
+	// they are left out of the state saved while the page is pending.
+	links  [][2]string
+	hit    bool
+	status int
+}
+
+func newVisit(url string, path string, pattern string, depth int) *visit {
+	v := visit{}
+	v.url = url
+	v.path = path
+	v.pattern = pattern
+	v.depth = depth
+	return &v
+}
+
+// NewCrawler creates a crawler with an empty graph and a default normalizer.
+func NewCrawler() *Crawler {
+	cr := Crawler{}
+	cr.Graph = graph.NewGraph()
+	cr.Normalizer = pattern.NewNormalizer()
+	cr.Verbosity = 1
+	cr.Out = os.Stdout
+	cr.pending = make(map[string]*visit)
+	cr.visited = make(map[string]bool)
+	return &cr
+}
+
+func (cr *Crawler) printPage(name string, depth int, id uint32, idx int) {
+	if cr.Verbosity >= 1 {
+		fmt.Fprintf(cr.Out, "%s[%d-%d] %s\n", strings.Repeat("  ", depth), id, idx, name)
+	}
+}
+
+// getVisit returns the visit of the request id, or nil if the request was not
+// made by the crawler.
+func (cr *Crawler) getVisit(id uint32) *visit {
+	if v, ok := cr.requests.Load(id); ok {
+		return v.(*visit)
+	}
+	util.LogPrintf("No visit of request %d", id)
+	return nil
+}
+
+func (cr *Crawler) isAsync() bool {
+	return cr.Parallel > 1 || len(cr.HostParallel) != 0
+}
+
+// getLimitRules returns the limit rules of the hosts, the first matching rule
+// applies so the catch-all rule comes last.
+func (cr *Crawler) getLimitRules() []*colly.LimitRule {
+	rules := []*colly.LimitRule{}
+	if !cr.isAsync() {
+		return rules
+	}
+
+	hosts := make([]string, 0, len(cr.HostParallel))
+	for host := range cr.HostParallel {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		rules = append(rules, &colly.LimitRule{DomainGlob: host, Parallelism: cr.HostParallel[host]})
+	}
+
+	parallel := cr.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	return append(rules, &colly.LimitRule{DomainGlob: "*", Parallelism: parallel})
+}
+
+// Crawl visits targetBase and the pages linked from it up to MaxDepth, and
+// builds the page graph.
+//
+// If a state is loaded by LoadState(), the crawl is resumed from its pending
+// pages instead.
+func (cr *Crawler) Crawl(targetBase string) error {
+	fullDomain, err := pattern.GetFullDomainName(targetBase)
+	if err != nil {
+		return err
+	}
+	domain, err := pattern.GetDomainName(targetBase)
+	if err != nil {
+		return err
+	}
+	cr.Normalizer.GenerateCustomRe(fullDomain)
+	listener := cr.Normalizer.AddRuleListener(func(r *pattern.Rule) {
+		cr.Graph.RenamePages(r.Apply)
+	})
+	defer cr.Normalizer.RemoveRuleListener(listener)
+
+	resume := cr.target != ""
+	if resume && cr.target != targetBase {
+		return fmt.Errorf("the crawl state is of %s, not %s", cr.target, targetBase)
+	}
+	cr.target = targetBase
+
+	if !resume {
+		cr.Graph.AddPage("/")
+		cr.printPage("/", 0, 0, 0)
+	}
+	c := colly.NewCollector(
+		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.80 Safari/537.36"),
+		colly.MaxDepth(cr.MaxDepth),
+		colly.Async(cr.isAsync()),
+	)
+	if err := c.Limits(cr.getLimitRules()); err != nil {
+		return err
+	}
+
+	// Find and visit all links
+	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		//fmt.Printf("a[href]: %s\n", e.Attr("href"))
+		//fmt.Printf("path: %s\n", e.Request.URL.Path)
+
+		r := e.Request
+		href := e.Attr("href")
+		after := r.URL.Path
+		if r.URL.RawQuery != "" {
+			after = strings.TrimRight(r.URL.Path, "/") + "?" + r.URL.RawQuery
+		}
+		if !strings.HasSuffix(after, "/") {
+			after += "/"
+		}
+
+		// Get index of "a[href]".
+		idx := e.Index
+
+		// Check redirection.
+		v := cr.getVisit(r.ID)
+		if v == nil {
+			return
+		}
+		before := v.path
+		if idx == 0 && before != after {
+			if cr.Verbosity >= 2 {
+				fmt.Fprintf(cr.Out, "(%s != %s)\n", before, after)
+			}
+			cr.Graph.AddRedirectPage(cr.Normalizer.GetPattern(before), cr.Normalizer.GetPattern(after))
+		}
+
+		// Get source from previous target.
+		source := v.pattern
+
+		// For breakpoint based on ID and index.
+		//if r.ID == 8 && idx == 8 {
+		//	println("breakpoint here.")
+		//}
+
+		target := pattern.StripDomainName(href, domain)
+
+		// Targets like "http://xxx.com", "mailto:xxx@xxx.com", "#tag" will be ignored.
+		if strings.HasPrefix(target, "http") || strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "#") {
+			return
+		}
+
+		// Targets like "images/test.jpg/" will be ignored.
+		//if !pattern.IsHtml(target) {
+		//	return
+		//}
+
+		// Convert relative URL to site-absolute URL.
+		// e.g., "./directions/index.html/" -> "/survivor/directions/index.html/"
+		target, err := pattern.GetAbsolutePath(r.URL.Path, target)
+		if err != nil {
+			util.LogPrintf("Skip link %q on %s: %v", href, r.URL, err)
+			return
+		}
+
+		// Enforce to add the trailing "/" for each path.
+		if !strings.HasSuffix(target, "/") {
+			target += "/"
+		}
+
+		status := "ok"
+		// The source is a pattern already, it is only renamed if an
+		// inferred rule has renamed its page since.
+		sPattern := cr.Graph.GetName(source)
+		tPattern := cr.Normalizer.GetPattern(target)
+		if sPattern == tPattern {
+			return
+		}
+		// Do not handle the main page again by recognizing "index.htm".
+		if strings.HasPrefix(tPattern, "/index.htm") {
+			return
+		}
+
+		// Adding the link tells whether the target is new in one step, so two
+		// pages crawled in parallel never visit the same target twice.
+		cr.stateMu.RLock()
+		var tv *visit
+		if cr.Graph.AddLink(sPattern, tPattern) {
+			cr.printPage(tPattern, r.Depth, r.ID, idx)
+			tv = newVisit(r.AbsoluteURL(href), target, tPattern, r.Depth+1)
+			cr.addPending(tv)
+		} else {
+			status = "already done"
+		}
+		v.links = append(v.links, [2]string{sPattern, tPattern})
+		cr.stateMu.RUnlock()
+		if cr.Verbosity >= 2 {
+			fmt.Fprintf(cr.Out, "New link: [%s] --> [%s]: %s\n", sPattern, tPattern, status)
+		}
+
+		if tv != nil {
+			cr.visits.Store(tv.url, tv)
+			if err := r.Visit(href); err != nil {
+				cr.visits.Delete(tv.url)
+				cr.removePending(tv, false)
+			}
+		}
+	})
+
+	c.OnRequest(func(r *colly.Request) {
+		//fmt.Printf("OnRequest: %s\n", r.URL.Path)
+		if v, ok := cr.visits.Load(r.URL.String()); ok {
+			cr.visits.Delete(r.URL.String())
+			cr.requests.Store(r.ID, v)
+		}
+		// A resumed crawl does not visit the crawled pages again.
+		if cr.isVisited(r.URL.String()) {
+			cr.removePending(cr.getVisit(r.ID), false)
+			r.Abort()
+		}
+	})
+
+	c.OnResponse(func(r *colly.Response) {
+		//fmt.Printf("OnResponse: %s\n", r.Request.URL.Path)
+		if v := cr.getVisit(r.Request.ID); v != nil {
+			cr.addHit(v, r.StatusCode)
+		}
+	})
+
+	// The pages failed like 404 are hits too.
+	c.OnError(func(r *colly.Response, err error) {
+		v := cr.getVisit(r.Request.ID)
+		if v == nil {
+			return
+		}
+		if r.StatusCode != 0 {
+			cr.addHit(v, r.StatusCode)
+		}
+		cr.removePending(v, true)
+	})
+
+	c.OnScraped(func(r *colly.Response) {
+		cr.removePending(cr.getVisit(r.Request.ID), true)
+	})
+
+	if resume {
+		return cr.resume(c)
+	}
+
+	// The home page is pending as the other pages, so it is crawled again if
+	// the crawl is saved before it is done.
+	home := newVisit(getRequestUrl(targetBase), "/", "/", 1)
+	cr.addPending(home)
+	cr.visits.Store(home.url, home)
+	if err := c.Visit(targetBase); err != nil {
+		cr.visits.Delete(home.url)
+		cr.removePending(home, false)
+		return err
+	}
+	c.Wait()
+	return nil
+}
@@ -0,0 +1,277 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/pattern"
+)
+
+// crawlState is the saved state of a crawl: the page graph, the rules in use
+// including the inferred ones, the paths seen by the inferrer, the crawled
+// URLs and the pending pages.
+type crawlState struct {
+	Target   string          `json:"target"`
+	Graph    *graph.Graph    `json:"graph"`
+	Rules    *pattern.Rules  `json:"rules,omitempty"`
+	Inferrer json.RawMessage `json:"inferrer,omitempty"`
+	Visited  []string        `json:"visited"`
+	Pending  []*pendingState `json:"pending"`
+}
+
+type pendingState struct {
+	Url     string `json:"url"`
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+	Depth   int    `json:"depth"`
+}
+
+// getRequestUrl gets the URL of the request to rawUrl like colly does, so the
+// visit of the first page can be found by its request.
+func getRequestUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	return u.String()
+}
+
+func (cr *Crawler) addPending(v *visit) {
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+
+	cr.pending[v.url] = v
+}
+
+// removePending removes the pending visit v when it is crawled, or skipped
+// if not visited. v may be nil.
+func (cr *Crawler) removePending(v *visit, visited bool) {
+	if v == nil || v.url == "" {
+		return
+	}
+
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+
+	delete(cr.pending, v.url)
+	if visited {
+		cr.visited[v.url] = true
+	}
+}
+
+func (cr *Crawler) isVisited(u string) bool {
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+
+	return cr.visited[u]
+}
+
+// getPending returns the pending visits sorted by their depths and URLs.
+func (cr *Crawler) getPending() []*visit {
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+
+	return cr.getPendingLocked()
+}
+
+func (cr *Crawler) getPendingLocked() []*visit {
+	vs := make([]*visit, 0, len(cr.pending))
+	for _, v := range cr.pending {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		if vs[i].depth != vs[j].depth {
+			return vs[i].depth < vs[j].depth
+		}
+		return vs[i].url < vs[j].url
+	})
+	return vs
+}
+
+// addHit adds the hit of v answered with status to the graph, see
+// SaveState().
+func (cr *Crawler) addHit(v *visit, status int) {
+	cr.stateMu.RLock()
+	defer cr.stateMu.RUnlock()
+
+	cr.Graph.AddHit(v.pattern, time.Now(), status)
+	v.hit = true
+	v.status = status
+}
+
+// getSavedGraph returns a copy of the graph without the links and hits added
+// by the pending pages, which are added again when the pages are crawled
+// after resuming.
+func (cr *Crawler) getSavedGraph(pending []*visit) (*graph.Graph, error) {
+	pages := cr.Graph.Pages()
+	pageMap := make(map[string]*graph.Page)
+	for _, page := range pages {
+		pageMap[page.Name] = page
+		for _, alias := range page.Aliases {
+			pageMap[alias] = page
+		}
+	}
+
+	for _, v := range pending {
+		if page, ok := pageMap[v.pattern]; ok && v.hit {
+			page.Hits--
+			if v.status != 0 {
+				page.Statuses[v.status]--
+				if page.Statuses[v.status] <= 0 {
+					delete(page.Statuses, v.status)
+				}
+			}
+		}
+		for _, link := range v.links {
+			source, ok := pageMap[link[0]]
+			target, targetOk := pageMap[link[1]]
+			if !ok || !targetOk {
+				continue
+			}
+			source.Links[target.Id]--
+			if source.Links[target.Id] <= 0 {
+				delete(source.Links, target.Id)
+			}
+		}
+	}
+	return graph.NewGraphOfPages(pages)
+}
+
+// resume visits the pending pages of the loaded state at their depths.
+func (cr *Crawler) resume(c *colly.Collector) error {
+	for _, v := range cr.getPending() {
+		data, err := json.Marshal(map[string]string{"URL": v.url, "Method": "GET"})
+		if err != nil {
+			return err
+		}
+		r, err := c.UnmarshalRequest(data)
+		if err != nil {
+			return err
+		}
+		r.Depth = v.depth
+
+		cr.visits.Store(v.url, v)
+		if err := r.Do(); err != nil {
+			cr.visits.Delete(v.url)
+			cr.removePending(v, false)
+		}
+	}
+	c.Wait()
+	return nil
+}
+
+// SaveState saves the state of the crawl to path, so it can be resumed with
+// LoadState() if interrupted. It can be called while crawling, the pages
+// being crawled are saved as pending without what they have added to the
+// graph, so they are counted once when crawled again after resuming.
+func (cr *Crawler) SaveState(path string) error {
+	cr.stateMu.Lock()
+	st := crawlState{}
+	st.Target = cr.target
+	if fullDomain, err := pattern.GetFullDomainName(cr.target); err == nil {
+		st.Rules = cr.Normalizer.ExportRules(fullDomain)
+	}
+	inferrer, err := cr.Normalizer.ExportInferrer()
+	if err != nil {
+		cr.stateMu.Unlock()
+		return err
+	}
+	st.Inferrer = inferrer
+	st.Visited = []string{}
+	st.Pending = []*pendingState{}
+
+	// A page moves from pending to visited at once in the state.
+	cr.pendingMu.Lock()
+	pending := cr.getPendingLocked()
+	for u := range cr.visited {
+		st.Visited = append(st.Visited, u)
+	}
+	cr.pendingMu.Unlock()
+	for _, v := range pending {
+		st.Pending = append(st.Pending, &pendingState{v.url, v.path, v.pattern, v.depth})
+	}
+	sort.Strings(st.Visited)
+
+	pg, err := cr.getSavedGraph(pending)
+	cr.stateMu.Unlock()
+	if err != nil {
+		return err
+	}
+	st.Graph = pg
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	// The old state is kept until the new one is written.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadState loads the state saved by SaveState() at path into the graph and
+// the normalizer of the crawler, so Crawl() resumes it with the same rules.
+func (cr *Crawler) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	st := crawlState{}
+	st.Graph = cr.Graph
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if st.Target == "" {
+		return fmt.Errorf("%s: no target of the crawl", path)
+	}
+	if st.Rules != nil {
+		if err := st.Rules.Validate(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		cr.Normalizer.SetRules(st.Rules)
+	}
+	// The inferrer goes on from the paths seen before if the crawl is
+	// resumed with -infer.
+	if err := cr.Normalizer.ImportInferrer(st.Inferrer); err != nil {
+		return fmt.Errorf("%s: inferrer: %v", path, err)
+	}
+
+	cr.pendingMu.Lock()
+	defer cr.pendingMu.Unlock()
+
+	cr.target = st.Target
+	for _, u := range st.Visited {
+		cr.visited[u] = true
+	}
+	for _, p := range st.Pending {
+		cr.pending[p.Url] = newVisit(p.Url, p.Path, p.Pattern, p.Depth)
+	}
+	return nil
+}
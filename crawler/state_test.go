@@ -0,0 +1,227 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hsluoyz/logdance/pattern"
+)
+
+// testSite is a site of the pages linking to their paths.
+var testSite = map[string][]string{
+	"/":  {"/a", "/b", "/a"},
+	"/a": {"/c"},
+	"/b": {"/d", "/c"},
+	"/c": {},
+	"/d": {"/"},
+}
+
+// newTestSite serves testSite.
+func newTestSite() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		links, ok := testSite[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>")
+		for _, link := range links {
+			fmt.Fprintf(w, "<a href=\"%s\">%s</a>", link, link)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+// blockingWriter discards the output of a crawler, the first write of line is
+// blocked until release is closed.
+type blockingWriter struct {
+	line    string
+	started chan bool
+	release chan bool
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.line) {
+		w.once.Do(func() {
+			w.started <- true
+			<-w.release
+		})
+	}
+	return len(p), nil
+}
+
+func newTestCrawler() *Crawler {
+	cr := NewCrawler()
+	cr.Verbosity = 0
+	cr.Out = ioutil.Discard
+	cr.Parallel = 2
+	return cr
+}
+
+// getLinks returns the links of the crawler with their weights.
+func getLinks(cr *Crawler) []string {
+	links := []string{}
+	for _, page := range cr.Graph.Pages() {
+		for target, weight := range page.Links {
+			links = append(links, fmt.Sprintf("%s -> %s: %d", page.Name, cr.Graph.GetPage(target).Name, weight))
+		}
+	}
+	sort.Strings(links)
+	return links
+}
+
+func testHits(t *testing.T, cr *Crawler) {
+	t.Helper()
+	for _, page := range cr.Graph.Pages() {
+		if page.Hits != 1 || page.Statuses[200] != 1 {
+			t.Errorf("%s has %d hits and statuses %v, supposed to be crawled once", page.Name, page.Hits, page.Statuses)
+		}
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	ts := newTestSite()
+	defer ts.Close()
+	target := ts.URL + "/"
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// The crawl is saved while "/b" is being crawled, after its link to "/d"
+	// is added.
+	rs, err := pattern.ParseRules([]byte(`{"sites": {"*": {"rules": [{"name": "x", "regex": "^/x/.*", "replace": "/x/"}]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := newTestCrawler()
+	cr.Normalizer.SetRules(rs)
+	cr.Verbosity = 2
+	w := &blockingWriter{line: "New link: [/b/] --> [/d/]", started: make(chan bool), release: make(chan bool)}
+	cr.Out = w
+	done := make(chan error)
+	go func() {
+		done <- cr.Crawl(target)
+	}()
+	<-w.started
+	if err := cr.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	close(w.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := newTestCrawler()
+	if err := resumed.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, v := range resumed.getPending() {
+		if v.url == ts.URL+"/b" && v.pattern == "/b/" && v.depth == 2 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("/b at the depth 2 is not pending")
+	}
+	if err := resumed.Crawl(ts.URL + "/other/"); err == nil {
+		t.Errorf("Crawl() of another target resumed, supposed to fail")
+	}
+	if err := resumed.Crawl(target); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rules are resumed with the crawl.
+	fullDomain, _ := pattern.GetFullDomainName(target)
+	if site := resumed.Normalizer.ExportRules(fullDomain).Sites[fullDomain]; len(site.Rules) != 1 || site.Rules[0].Name != "x" {
+		t.Errorf("resumed rules = %+v, supposed to be the rule x", site.Rules)
+	}
+
+	// "/b" is crawled again, its links and hit are counted once.
+	want := []string{"/ -> /a/: 2", "/ -> /b/: 1", "/a/ -> /c/: 1", "/b/ -> /c/: 1", "/b/ -> /d/: 1", "/d/ -> /: 1"}
+	if links := getLinks(cr); !reflect.DeepEqual(links, want) {
+		t.Errorf("links = %v, supposed to be %v", links, want)
+	}
+	testHits(t, cr)
+	if links := getLinks(resumed); !reflect.DeepEqual(links, want) {
+		t.Errorf("resumed links = %v, supposed to be %v", links, want)
+	}
+	testHits(t, resumed)
+
+	// The state of a finished crawl has nothing to resume.
+	if err := resumed.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	again := newTestCrawler()
+	if err := again.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Crawl(target); err != nil {
+		t.Fatal(err)
+	}
+	if links := getLinks(again); !reflect.DeepEqual(links, want) {
+		t.Errorf("links after resuming a finished crawl = %v, supposed to be %v", links, want)
+	}
+	testHits(t, again)
+}
+
+func TestSaveStateInferrer(t *testing.T) {
+	cr := newTestCrawler()
+	cr.Normalizer.SetInferrer(pattern.NewInferrer(3))
+	cr.target = "http://example.com/"
+	cr.Normalizer.GetPattern("/user/alice/")
+	cr.Normalizer.GetPattern("/user/bob/")
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := cr.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resumed inferrer counts the siblings seen before.
+	resumed := newTestCrawler()
+	resumed.Normalizer.SetInferrer(pattern.NewInferrer(3))
+	if err := resumed.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if res := resumed.Normalizer.GetPattern("/user/carol/"); res != "/user/*/" {
+		t.Errorf("GetPattern(/user/carol/) = %s, supposed to be /user/*/", res)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir := t.TempDir()
+	cr := newTestCrawler()
+	if err := cr.LoadState(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("LoadState() of a missing file succeeded, supposed to fail")
+	}
+
+	path := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(path, []byte(`{"graph": {"pages": []}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.LoadState(path); err == nil {
+		t.Errorf("LoadState() of a state without target succeeded, supposed to fail")
+	}
+}
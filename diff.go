@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/render"
+)
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff")
+	out := fs.String("o", "webgraph-diff.json", "output path of the diff graph for index.html, empty to skip it")
+	report := fs.String("report", "", "output path of the JSON report, the standard output if empty")
+	inFormat := fs.String("in-format", "", fmt.Sprintf("format of the graphs, one of %v, by the extensions of the files if empty", render.GetReadableFormatNames()))
+	fs.IntVar(&verbosity, "v", 1, "verbosity: 0 quiet, 1 the numbers of the changes")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	old, err := render.Load(fs.Arg(0), *inFormat)
+	if err != nil {
+		return err
+	}
+	next, err := render.Load(fs.Arg(1), *inFormat)
+	if err != nil {
+		return err
+	}
+	d := graph.Diff(old, next)
+	r := d.Report()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *report == "" {
+		os.Stdout.Write(data)
+	} else if err := ioutil.WriteFile(*report, data, 0644); err != nil {
+		return err
+	}
+
+	// The numbers go to the standard error, so the report can be piped.
+	if verbosity >= 1 {
+		fmt.Fprintf(os.Stderr, "%d pages added, %d removed, %d changed, %d links added, %d removed, %d changed\n",
+			len(r.AddedPages), len(r.RemovedPages), len(r.ChangedPages), len(r.AddedLinks), len(r.RemovedLinks), len(r.ChangedLinks))
+	}
+	if *out != "" {
+		return render.GenerateDiffJson(d, *out)
+	}
+	return nil
+}
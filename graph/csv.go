@@ -0,0 +1,311 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Category gets the category of the page: "home" for the home page, or "page".
+func (p *Page) Category() string {
+	if p.Name == "/" {
+		return "home"
+	}
+	return "page"
+}
+
+// FormatAliases formats the aliases of a page as a JSON array like
+// `["/home/","/index b/"]`, because a path can have spaces. It is "" if there
+// are no aliases.
+func FormatAliases(aliases []string) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(aliases)
+	return string(data)
+}
+
+// ParseAliases parses the aliases formatted by FormatAliases(), the aliases
+// separated by spaces like "/home/ /index/" are read too.
+func ParseAliases(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") {
+		return strings.Fields(value), nil
+	}
+
+	var aliases []string
+	if err := json.Unmarshal([]byte(value), &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// Depths returns the depth of each page by the links from the home page, the
+// pages not reachable from it are not included.
+func (g *Graph) Depths() map[int]int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	depths := make(map[int]int)
+	home, ok := g.pageMap["/"]
+	if !ok {
+		return depths
+	}
+
+	depths[home.Id] = 0
+	queue := []*Page{home}
+	for len(queue) != 0 {
+		page := queue[0]
+		queue = queue[1:]
+
+		targets := make([]int, 0, len(page.Links))
+		for target := range page.Links {
+			targets = append(targets, target)
+		}
+		sort.Ints(targets)
+		for _, target := range targets {
+			if _, ok := depths[target]; !ok && g.pageList[target] != nil {
+				depths[target] = depths[page.Id] + 1
+				queue = append(queue, g.pageList[target])
+			}
+		}
+	}
+	return depths
+}
+
+func newCsvWriter(w io.Writer, comma rune) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return cw
+}
+
+// WriteNodesCsv writes the pages to w as CSV with the header, comma is the
+// field delimiter like ',' or '\t'. The depth is empty for the pages not
+// reachable from the home page.
+func (g *Graph) WriteNodesCsv(w io.Writer, comma rune) error {
+	pages := g.Pages()
+	depths := g.Depths()
+	inDegrees := make(map[int]int)
+	for _, page := range pages {
+		for target := range page.Links {
+			inDegrees[target]++
+		}
+	}
+
+	cw := newCsvWriter(w, comma)
+	cw.Write([]string{"id", "pattern", "aliases", "category", "depth", "in_degree", "out_degree", "hits"})
+	for _, page := range pages {
+		depth := ""
+		if d, ok := depths[page.Id]; ok {
+			depth = strconv.Itoa(d)
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(page.Id),
+			page.Name,
+			FormatAliases(page.Aliases),
+			page.Category(),
+			depth,
+			strconv.Itoa(inDegrees[page.Id]),
+			strconv.Itoa(len(page.Links)),
+			strconv.Itoa(page.Hits),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteEdgesCsv writes the links to w as CSV with the header, comma is the
+// field delimiter like ',' or '\t'. The kind of each edge is "link", the
+// redirections are written by WriteRedirectsCsv().
+func (g *Graph) WriteEdgesCsv(w io.Writer, comma rune) error {
+	pages := g.Pages()
+	names := make(map[int]string)
+	for _, page := range pages {
+		names[page.Id] = page.Name
+	}
+
+	cw := newCsvWriter(w, comma)
+	cw.Write([]string{"source", "target", "source_pattern", "target_pattern", "weight", "kind"})
+	for _, page := range pages {
+		targets := make([]int, 0, len(page.Links))
+		for target := range page.Links {
+			targets = append(targets, target)
+		}
+		sort.Ints(targets)
+
+		for _, target := range targets {
+			cw.Write([]string{
+				strconv.Itoa(page.Id),
+				strconv.Itoa(target),
+				page.Name,
+				names[target],
+				strconv.Itoa(page.Links[target]),
+				"link",
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteRedirectsCsv writes the redirections to w as CSV with the header, comma
+// is the field delimiter like ',' or '\t'. A redirection is between two names
+// of a page, so it is written with the page instead of as an edge.
+func (g *Graph) WriteRedirectsCsv(w io.Writer, comma rune) error {
+	cw := newCsvWriter(w, comma)
+	cw.Write([]string{"page", "pattern", "from", "to"})
+	for _, page := range g.Pages() {
+		for _, r := range page.Redirects {
+			cw.Write([]string{strconv.Itoa(page.Id), page.Name, r.From, r.To})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readCsv reads the records of a CSV with the header from r, comma is the field
+// delimiter. get gets a field of a record by the column name in the header,
+// the columns of required must be in the header.
+func readCsv(r io.Reader, comma rune, required []string, read func(get func(name string) string) error) error {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("no column %s", name)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		if err := read(get); err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+}
+
+// ReadCsv reads the page graph from the node, edge and redirection lists
+// written by WriteNodesCsv(), WriteEdgesCsv() and WriteRedirectsCsv(), comma
+// is the field delimiter. The redirections are optional, redirects can be
+// nil. The columns are found by the headers, so the lists edited in a
+// spreadsheet can be read too.
+func ReadCsv(nodes io.Reader, edges io.Reader, redirects io.Reader, comma rune) (*Graph, error) {
+	pages := []*Page{}
+	pageMap := make(map[int]*Page)
+	err := readCsv(nodes, comma, []string{"id", "pattern"}, func(get func(name string) string) error {
+		id, err := strconv.Atoi(get("id"))
+		if err != nil {
+			return err
+		}
+		page := Page{}
+		page.Id = id
+		page.Name = get("pattern")
+		if page.Aliases, err = ParseAliases(get("aliases")); err != nil {
+			return err
+		}
+		page.Links = make(map[int]int)
+		if hits := get("hits"); hits != "" {
+			if page.Hits, err = strconv.Atoi(hits); err != nil {
+				return err
+			}
+		}
+		pages = append(pages, &page)
+		pageMap[id] = &page
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("nodes: %v", err)
+	}
+
+	err = readCsv(edges, comma, []string{"source", "target"}, func(get func(name string) string) error {
+		source, err := strconv.Atoi(get("source"))
+		if err != nil {
+			return err
+		}
+		target, err := strconv.Atoi(get("target"))
+		if err != nil {
+			return err
+		}
+		page, ok := pageMap[source]
+		if !ok {
+			return fmt.Errorf("edge %d -> %d is from a missing page", source, target)
+		}
+
+		// The redirections were edges of the kind "redirect" before they had
+		// their own list.
+		if get("kind") == "redirect" {
+			page.addRedirect(get("source_pattern"), get("target_pattern"))
+			return nil
+		}
+		weight := 1
+		if w := get("weight"); w != "" {
+			if weight, err = strconv.Atoi(w); err != nil {
+				return err
+			}
+		}
+		page.Links[target] += weight
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("edges: %v", err)
+	}
+
+	if redirects != nil {
+		err = readCsv(redirects, comma, []string{"page", "from", "to"}, func(get func(name string) string) error {
+			id, err := strconv.Atoi(get("page"))
+			if err != nil {
+				return err
+			}
+			page, ok := pageMap[id]
+			if !ok {
+				return fmt.Errorf("redirection of the missing page %d", id)
+			}
+			page.addRedirect(get("from"), get("to"))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("redirects: %v", err)
+		}
+	}
+	return NewGraphOfPages(pages)
+}
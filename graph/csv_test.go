@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCsvTestGraph() *Graph {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddRedirectPage("/", "/home/")
+	g.AddLink("/", "/a/")
+	g.AddLink("/", "/a/")
+	g.AddLink("/a/", "/b,c/")
+	g.AddLink("/d/", "/a/")
+	return g
+}
+
+func TestDepths(t *testing.T) {
+	depths := newCsvTestGraph().Depths()
+	if !reflect.DeepEqual(depths, map[int]int{0: 0, 1: 1, 2: 2}) {
+		t.Errorf("Depths() = %v, supposed to be map[0:0 1:1 2:2]", depths)
+	}
+}
+
+func TestWriteNodesCsv(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := newCsvTestGraph().WriteNodesCsv(&b, ','); err != nil {
+		t.Fatal(err)
+	}
+
+	res := `id,pattern,aliases,category,depth,in_degree,out_degree,hits
+0,/,"[""/home/""]",home,0,0,1,0
+1,/a/,,page,1,2,1,0
+2,"/b,c/",,page,2,1,0,0
+3,/d/,,page,,0,1,0
+`
+	if b.String() != res {
+		t.Errorf("WriteNodesCsv() = %s, supposed to be %s", b.String(), res)
+	}
+}
+
+func TestWriteEdgesCsv(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := newCsvTestGraph().WriteEdgesCsv(&b, '\t'); err != nil {
+		t.Fatal(err)
+	}
+
+	res := "source\ttarget\tsource_pattern\ttarget_pattern\tweight\tkind\n" +
+		"0\t1\t/\t/a/\t2\tlink\n" +
+		"1\t2\t/a/\t/b,c/\t1\tlink\n" +
+		"3\t1\t/d/\t/a/\t1\tlink\n"
+	if b.String() != res {
+		t.Errorf("WriteEdgesCsv() = %q, supposed to be %q", b.String(), res)
+	}
+}
+
+func TestWriteRedirectsCsv(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := newCsvTestGraph().WriteRedirectsCsv(&b, ','); err != nil {
+		t.Fatal(err)
+	}
+
+	res := "page,pattern,from,to\n0,/,/,/home/\n"
+	if b.String() != res {
+		t.Errorf("WriteRedirectsCsv() = %q, supposed to be %q", b.String(), res)
+	}
+}
+
+func TestReadCsv(t *testing.T) {
+	g := newCsvTestGraph()
+	g.AddHit("/a/", time.Time{}, 0)
+	// A path can have spaces.
+	g.AddLink("/d/", "/e f/")
+	g.AddRedirectPage("/e f/", "/e/")
+	nodes, edges, redirects := bytes.Buffer{}, bytes.Buffer{}, bytes.Buffer{}
+	if err := g.WriteNodesCsv(&nodes, '\t'); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteEdgesCsv(&edges, '\t'); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteRedirectsCsv(&redirects, '\t'); err != nil {
+		t.Fatal(err)
+	}
+
+	g2, err := ReadCsv(&nodes, &edges, &redirects, '\t')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g2.Pages(), g.Pages()) {
+		t.Errorf("ReadCsv() = %+v, supposed to be %+v", g2.Pages(), g.Pages())
+	}
+	if e := g2.GetPageByName("/e f/"); e == nil || e.Name != "/e/" {
+		t.Errorf("GetPageByName(/e f/) = %+v, supposed to be /e/", e)
+	}
+
+	// The columns are found by the header.
+	g3, err := ReadCsv(strings.NewReader("pattern,id\n/,0\n/a/,1\n"), strings.NewReader("target,source\n1,0\n"), nil, ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home := g3.GetPageByName("/"); home == nil || !reflect.DeepEqual(home.Links, map[int]int{1: 1}) {
+		t.Errorf("/ = %+v, supposed to link to /a/ once", home)
+	}
+
+	// The aliases separated by spaces and the redirections as edges of the
+	// kind "redirect" are read too.
+	g4, err := ReadCsv(strings.NewReader("id,pattern,aliases\n0,/home/,/ /index/\n"), strings.NewReader("source,target,source_pattern,target_pattern,weight,kind\n0,0,/,/home/,1,redirect\n"), nil, ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home := g4.GetPageByName("/index/"); home == nil || home.Name != "/home/" || len(home.Links) != 0 || len(home.Redirects) != 1 {
+		t.Errorf("GetPageByName(/index/) = %+v, supposed to be /home/ redirected from /", home)
+	}
+
+	bad := [][3]string{
+		{"id\n0\n", "source,target\n", ""},
+		{"id,pattern\nx,/\n", "source,target\n", ""},
+		{"id,pattern\n0,/\n", "source,target\n1,0\n", ""},
+		{"id,pattern\n0,/\n", "source,target,weight\n0,0,x\n", ""},
+		{"id,pattern,aliases\n0,/,[/home/\n", "source,target\n", ""},
+		{"id,pattern\n0,/\n", "source,target\n", "page,from\n0,/\n"},
+		{"id,pattern\n0,/\n", "source,target\n", "page,from,to\n1,/,/home/\n"},
+	}
+	for _, b := range bad {
+		var redirects io.Reader
+		if b[2] != "" {
+			redirects = strings.NewReader(b[2])
+		}
+		if _, err := ReadCsv(strings.NewReader(b[0]), strings.NewReader(b[1]), redirects, ','); err == nil {
+			t.Errorf("ReadCsv(%q, %q, %q) succeeded, supposed to fail", b[0], b[1], b[2])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"sort"
+)
+
+// The statuses of the pages and links in a diff.
+const (
+	DiffSame    = "same"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	// DiffChanged is a link of another weight, or a page of another name
+	// like a page redirected to a new path.
+	DiffChanged = "changed"
+)
+
+// GraphDiff is the difference between an old and a new page graph, like two
+// crawls of a site or two time windows of its logs. Its pages are the pages of
+// both, the pages of the new graph keep their ids and the removed pages have
+// the ids after them.
+type GraphDiff struct {
+	Pages []*PageDiff
+	Links []*LinkDiff
+}
+
+// PageDiff is a page of a diff, Name is the name in the new graph if the page
+// is not removed. OldName is the name in the old graph of a changed page.
+type PageDiff struct {
+	Id      int
+	Name    string
+	Status  string
+	OldName string
+}
+
+// LinkDiff is a link of a diff between the pages Source and Target of the
+// diff, its weight is 0 in the graph without it.
+type LinkDiff struct {
+	Source    int
+	Target    int
+	OldWeight int
+	NewWeight int
+	Status    string
+}
+
+// Diff compares the graph oldGraph to newGraph. The pages are matched by their
+// names and aliases, so a page renamed by a redirection is still the same
+// page.
+func Diff(oldGraph *Graph, newGraph *Graph) *GraphDiff {
+	oldPages := oldGraph.Pages()
+	newPages := newGraph.Pages()
+
+	oldNames := make(map[string]*Page)
+	for _, page := range oldPages {
+		for _, name := range append([]string{page.Name}, page.Aliases...) {
+			oldNames[name] = page
+		}
+	}
+
+	// ids maps the old ids to the ids of the diff.
+	d := GraphDiff{}
+	ids := make(map[int]int)
+	maxId := -1
+	for _, page := range newPages {
+		pd := PageDiff{page.Id, page.Name, DiffAdded, ""}
+		for _, name := range append([]string{page.Name}, page.Aliases...) {
+			if p, ok := oldNames[name]; ok {
+				if _, matched := ids[p.Id]; !matched {
+					ids[p.Id] = page.Id
+					pd.Status = DiffSame
+					if p.Name != page.Name {
+						pd.Status = DiffChanged
+						pd.OldName = p.Name
+					}
+					break
+				}
+			}
+		}
+		d.Pages = append(d.Pages, &pd)
+		if page.Id > maxId {
+			maxId = page.Id
+		}
+	}
+	for _, page := range oldPages {
+		if _, ok := ids[page.Id]; !ok {
+			maxId++
+			ids[page.Id] = maxId
+			d.Pages = append(d.Pages, &PageDiff{maxId, page.Name, DiffRemoved, ""})
+		}
+	}
+
+	links := make(map[[2]int]*LinkDiff)
+	for _, page := range newPages {
+		for target, count := range page.Links {
+			links[[2]int{page.Id, target}] = &LinkDiff{page.Id, target, 0, count, DiffAdded}
+		}
+	}
+	for _, page := range oldPages {
+		for target, count := range page.Links {
+			key := [2]int{ids[page.Id], ids[target]}
+			l, ok := links[key]
+			if !ok {
+				l = &LinkDiff{key[0], key[1], 0, 0, DiffRemoved}
+				links[key] = l
+			}
+			l.OldWeight = count
+			if l.NewWeight == 0 {
+				continue
+			}
+			l.Status = DiffSame
+			if l.OldWeight != l.NewWeight {
+				l.Status = DiffChanged
+			}
+		}
+	}
+
+	for _, l := range links {
+		d.Links = append(d.Links, l)
+	}
+	sort.Slice(d.Links, func(i, j int) bool {
+		if d.Links[i].Source != d.Links[j].Source {
+			return d.Links[i].Source < d.Links[j].Source
+		}
+		return d.Links[i].Target < d.Links[j].Target
+	})
+	return &d
+}
+
+// DiffReport is the report of a diff by the page names, only the changes are
+// listed.
+type DiffReport struct {
+	AddedPages   []string         `json:"addedPages"`
+	RemovedPages []string         `json:"removedPages"`
+	ChangedPages []PageDiffReport `json:"changedPages"`
+	AddedLinks   []LinkDiffReport `json:"addedLinks"`
+	RemovedLinks []LinkDiffReport `json:"removedLinks"`
+	ChangedLinks []LinkDiffReport `json:"changedLinks"`
+}
+
+// PageDiffReport is a changed page of a diff report with its old name.
+type PageDiffReport struct {
+	Name    string `json:"name"`
+	OldName string `json:"oldName"`
+}
+
+// LinkDiffReport is a link of a diff report.
+type LinkDiffReport struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	OldWeight int    `json:"oldWeight"`
+	NewWeight int    `json:"newWeight"`
+}
+
+// Report returns the report of the diff.
+func (d *GraphDiff) Report() *DiffReport {
+	r := DiffReport{}
+	r.AddedPages = []string{}
+	r.RemovedPages = []string{}
+	r.ChangedPages = []PageDiffReport{}
+	r.AddedLinks = []LinkDiffReport{}
+	r.RemovedLinks = []LinkDiffReport{}
+	r.ChangedLinks = []LinkDiffReport{}
+
+	names := make(map[int]string)
+	for _, p := range d.Pages {
+		names[p.Id] = p.Name
+		switch p.Status {
+		case DiffAdded:
+			r.AddedPages = append(r.AddedPages, p.Name)
+		case DiffRemoved:
+			r.RemovedPages = append(r.RemovedPages, p.Name)
+		case DiffChanged:
+			r.ChangedPages = append(r.ChangedPages, PageDiffReport{p.Name, p.OldName})
+		}
+	}
+
+	for _, l := range d.Links {
+		lr := LinkDiffReport{names[l.Source], names[l.Target], l.OldWeight, l.NewWeight}
+		switch l.Status {
+		case DiffAdded:
+			r.AddedLinks = append(r.AddedLinks, lr)
+		case DiffRemoved:
+			r.RemovedLinks = append(r.RemovedLinks, lr)
+		case DiffChanged:
+			r.ChangedLinks = append(r.ChangedLinks, lr)
+		}
+	}
+	return &r
+}
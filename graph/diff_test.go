@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := NewGraph()
+	old.AddPage("/")
+	old.AddLink("/", "/a/")
+	old.AddLink("/", "/b/")
+	old.AddLink("/", "/b/")
+	old.AddLink("/b/", "/c/")
+	old.AddLink("/c/", "/a/")
+
+	// "/a/" is redirected to "/new-a/", "/c/" is removed and "/d/" is added.
+	next := NewGraph()
+	next.AddPage("/")
+	next.AddLink("/", "/b/")
+	next.AddLink("/", "/b/")
+	next.AddLink("/", "/b/")
+	next.AddLink("/", "/d/")
+	next.AddLink("/", "/new-a/")
+	next.AddRedirectPage("/a/", "/new-a/")
+
+	d := Diff(old, next)
+	pages := []PageDiff{
+		{0, "/", DiffSame, ""},
+		{1, "/b/", DiffSame, ""},
+		{2, "/d/", DiffAdded, ""},
+		{3, "/new-a/", DiffChanged, "/a/"},
+		{4, "/c/", DiffRemoved, ""},
+	}
+	for i, p := range d.Pages {
+		if i >= len(pages) || *p != pages[i] {
+			t.Errorf("Pages[%d] = %+v", i, *p)
+		}
+	}
+	if len(d.Pages) != len(pages) {
+		t.Errorf("len(Pages) = %d, supposed to be %d", len(d.Pages), len(pages))
+	}
+
+	links := []LinkDiff{
+		{0, 1, 2, 3, DiffChanged},
+		{0, 2, 0, 1, DiffAdded},
+		{0, 3, 1, 1, DiffSame},
+		{1, 4, 1, 0, DiffRemoved},
+		{4, 3, 1, 0, DiffRemoved},
+	}
+	for i, l := range d.Links {
+		if i >= len(links) || *l != links[i] {
+			t.Errorf("Links[%d] = %+v", i, *l)
+		}
+	}
+	if len(d.Links) != len(links) {
+		t.Errorf("len(Links) = %d, supposed to be %d", len(d.Links), len(links))
+	}
+
+	r := d.Report()
+	if !reflect.DeepEqual(r.AddedPages, []string{"/d/"}) || !reflect.DeepEqual(r.RemovedPages, []string{"/c/"}) {
+		t.Errorf("AddedPages, RemovedPages = %v, %v, supposed to be [/d/], [/c/]", r.AddedPages, r.RemovedPages)
+	}
+	if !reflect.DeepEqual(r.ChangedPages, []PageDiffReport{{"/new-a/", "/a/"}}) {
+		t.Errorf("ChangedPages = %+v, supposed to be /new-a/ renamed from /a/", r.ChangedPages)
+	}
+	if !reflect.DeepEqual(r.ChangedLinks, []LinkDiffReport{{"/", "/b/", 2, 3}}) {
+		t.Errorf("ChangedLinks = %+v, supposed to be / -> /b/ from 2 to 3", r.ChangedLinks)
+	}
+	if len(r.AddedLinks) != 1 || len(r.RemovedLinks) != 2 || r.RemovedLinks[1].Source != "/c/" {
+		t.Errorf("AddedLinks, RemovedLinks = %+v, %+v, supposed to be 1 and 2 links", r.AddedLinks, r.RemovedLinks)
+	}
+}
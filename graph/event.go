@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+// The types of the events.
+const (
+	// EventPage is a new page, or a page renamed by a redirection.
+	EventPage = "page"
+	// EventLink is a new link, or a link of a new weight.
+	EventLink = "link"
+	// EventRemove is a page merged into the page of Target.
+	EventRemove = "remove"
+)
+
+// Event is a change of the graph, so the graph can be watched while it is
+// being built.
+type Event struct {
+	Type string `json:"type"`
+	// Id and Name are the page of EventPage and EventRemove.
+	Id   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Source, Target and Weight are the link of EventLink, Target is also
+	// the page merged into of EventRemove.
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Weight int `json:"weight,omitempty"`
+}
+
+// AddListener adds the listener f of the events of the graph, and returns its
+// id for RemoveListener(). f is called in the order of the changes while the
+// graph is locked, so it must be quick and not call the graph.
+func (g *Graph) AddListener(f func(e Event)) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.listeners == nil {
+		g.listeners = make(map[int]func(e Event))
+	}
+	g.nextListener++
+	g.listeners[g.nextListener] = f
+	return g.nextListener
+}
+
+// RemoveListener removes the listener of id returned by AddListener().
+func (g *Graph) RemoveListener(id int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.listeners, id)
+}
+
+func (g *Graph) emit(e Event) {
+	for _, f := range g.listeners {
+		f(e)
+	}
+}
+
+func (g *Graph) emitPage(p *Page) {
+	if len(g.listeners) != 0 {
+		g.emit(Event{Type: EventPage, Id: p.Id, Name: p.Name})
+	}
+}
+
+func (g *Graph) emitLink(p *Page, target int) {
+	if len(g.listeners) != 0 {
+		g.emit(Event{Type: EventLink, Source: p.Id, Target: target, Weight: p.Links[target]})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddListener(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddLink("/", "/home/")
+
+	var events []Event
+	g.AddListener(func(e Event) {
+		events = append(events, e)
+	})
+	g.AddLink("/", "/a/")
+	g.AddLink("/home/", "/a/")
+	g.AddLink("/", "/a/")
+	g.AddRedirectPage("/", "/home/")
+	g.AddRedirectPage("/b/long/", "/b/")
+
+	want := []Event{
+		{Type: EventPage, Id: 2, Name: "/a/"},
+		{Type: EventLink, Source: 0, Target: 2, Weight: 1},
+		{Type: EventLink, Source: 1, Target: 2, Weight: 1},
+		{Type: EventLink, Source: 0, Target: 2, Weight: 2},
+		// "/home/" is merged into "/", its link to "/a/" is moved.
+		{Type: EventRemove, Id: 1, Target: 0},
+		{Type: EventLink, Source: 0, Target: 2, Weight: 3},
+		// "/b/long/" is renamed to the shorter "/b/".
+		{Type: EventPage, Id: 3, Name: "/b/long/"},
+		{Type: EventPage, Id: 3, Name: "/b/"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, supposed to be %+v", events, want)
+	}
+}
+
+func TestRemoveListener(t *testing.T) {
+	g := NewGraph()
+	count := 0
+	id := g.AddListener(func(e Event) {
+		count++
+	})
+	g.AddPage("/")
+	g.RemoveListener(id)
+	g.AddPage("/a/")
+	g.RemoveListener(id)
+
+	if count != 1 {
+		t.Errorf("%d events, supposed to be 1 before the listener is removed", count)
+	}
+}
@@ -14,17 +14,57 @@
 
 package graph
 
-import "github.com/hsluoyz/logdance/util"
+import (
+	"sync"
+	"time"
+
+	"github.com/hsluoyz/logdance/util"
+)
 
 type Page struct {
-	Id      int         `json:"nodes"`
-	Name    string      `json:"nodes"`
-	Aliases []string    `json:"nodes"`
-	Links   map[int]int `json:"nodes"`
+	Id      int         `json:"id"`
+	Name    string      `json:"name"`
+	Aliases []string    `json:"aliases"`
+	Links   map[int]int `json:"links"`
+
+	// Hits is the number of the requests to the page, FirstSeen and
+	// LastSeen are the times of the first and last ones.
+	Hits      int       `json:"hits"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Statuses counts the hits by their HTTP status codes.
+	Statuses map[int]int `json:"statuses,omitempty"`
+
+	// Redirects are the redirections seen between the names of the page.
+	Redirects []Redirect `json:"redirects,omitempty"`
+}
+
+// Redirect is a redirection from the path From to To.
+type Redirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is a page store, it owns the pages and the links between them. The id
+// of a page never changes: when two pages are merged, the merged page leaves
+// a hole in the page list and the links to it are rewritten.
+//
+// A graph is safe for concurrent use, the pages it returns are copies.
+type Graph struct {
+	mu           sync.RWMutex
+	pageList     []*Page
+	pageMap      map[string]*Page
+	listeners    map[int]func(e Event)
+	nextListener int
 }
 
-var PageList = []*Page{}
-var PageMap = map[string]*Page{}
+// NewGraph creates an empty page graph.
+func NewGraph() *Graph {
+	g := Graph{}
+	g.pageList = []*Page{}
+	g.pageMap = map[string]*Page{}
+	return &g
+}
 
 func newPage(id int, name string) *Page {
 	p := Page{}
@@ -37,66 +77,317 @@ func newPage(id int, name string) *Page {
 	return &p
 }
 
+func (p *Page) copy() *Page {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	c.Aliases = append([]string(nil), p.Aliases...)
+	c.Redirects = append([]Redirect(nil), p.Redirects...)
+	if p.Statuses != nil {
+		c.Statuses = make(map[int]int, len(p.Statuses))
+		for status, count := range p.Statuses {
+			c.Statuses[status] = count
+		}
+	}
+	c.Links = make(map[int]int, len(p.Links))
+	for target, count := range p.Links {
+		c.Links[target] = count
+	}
+	return &c
+}
+
 func (p *Page) addAlias(name string) {
 	p.Aliases = append(p.Aliases, name)
 }
 
-func (p *Page) addLink(path string) {
-	target, ok := PageMap[path]
-	if !ok {
-		target = newPage(len(PageList), path)
-		PageList = append(PageList, target)
-		PageMap[path] = target
+func (p *Page) addRedirect(from string, to string) {
+	for _, r := range p.Redirects {
+		if r.From == from && r.To == to {
+			return
+		}
+	}
+	p.Redirects = append(p.Redirects, Redirect{from, to})
+}
+
+func (p *Page) addStatus(status int, count int) {
+	if p.Statuses == nil {
+		p.Statuses = make(map[int]int)
+	}
+	p.Statuses[status] += count
+}
+
+// addHits adds count hits seen from first to last to p.
+func (p *Page) addHits(count int, first time.Time, last time.Time) {
+	if count == 0 {
+		return
 	}
 
+	p.Hits += count
+	if p.FirstSeen.IsZero() || first.Before(p.FirstSeen) {
+		p.FirstSeen = first
+	}
+	if last.After(p.LastSeen) {
+		p.LastSeen = last
+	}
+}
+
+func (g *Graph) addPage(name string) *Page {
+	page := newPage(len(g.pageList), name)
+	g.pageList = append(g.pageList, page)
+	g.pageMap[name] = page
+	g.emitPage(page)
+	return page
+}
+
+func (g *Graph) getOrAddPage(name string) *Page {
+	if page, ok := g.pageMap[name]; ok {
+		return page
+	}
+	return g.addPage(name)
+}
+
+// addLink adds a link from p to path, and returns true if the page of path is
+// new.
+func (g *Graph) addLink(p *Page, path string) bool {
+	_, ok := g.pageMap[path]
+	target := g.getOrAddPage(path)
+
 	if _, ok := p.Links[target.Id]; ok {
 		p.Links[target.Id]++
 	} else {
 		p.Links[target.Id] = 1
 	}
+	g.emitLink(p, target.Id)
+	return !ok
+}
+
+// Pages returns the pages of the graph, ordered by id.
+func (g *Graph) Pages() []*Page {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pages := make([]*Page, 0, len(g.pageList))
+	for _, page := range g.pageList {
+		if page != nil {
+			pages = append(pages, page.copy())
+		}
+	}
+	return pages
+}
+
+// GetPage returns the page of id, or nil if there is no such page or it has
+// been merged into another page.
+func (g *Graph) GetPage(id int) *Page {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if id < 0 || id >= len(g.pageList) {
+		return nil
+	}
+	return g.pageList[id].copy()
+}
+
+// GetPageByName returns the page of name or alias, or nil if not found.
+func (g *Graph) GetPageByName(name string) *Page {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.pageMap[name].copy()
+}
+
+// GetName returns the name of the page of name or alias, e.g., the new name of
+// a renamed page, or name itself if not found.
+func (g *Graph) GetName(name string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if p, ok := g.pageMap[name]; ok {
+		return p.Name
+	}
+	return name
 }
 
-func AddPage(name string) {
-	newPage := newPage(len(PageList), name)
-	PageList = append(PageList, newPage)
-	PageMap[name] = newPage
+func (g *Graph) AddPage(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.getOrAddPage(name)
 }
 
 // For redirect: "/" -> "/home.html/",
 // before = "/"
 // after = "/home.html/"
-func AddRedirectPage(before string, after string) {
-	page, ok := PageMap[before]
-	if !ok {
-		panic("\"before\" of a redirection does not exist")
+func (g *Graph) AddRedirectPage(before string, after string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	page, ok := g.pageMap[before]
+	afterPage, afterOk := g.pageMap[after]
+	if !ok && !afterOk {
+		// The redirection is seen before any link to it.
+		page = g.addPage(before)
+		ok = true
+	}
+
+	if before == after {
+		return
 	}
 
-	afterPage, ok := PageMap[after]
 	if !ok {
+		afterPage.addAlias(before)
+		afterPage.addRedirect(before, after)
+		g.pageMap[before] = afterPage
+	} else if !afterOk {
 		// Maybe:
 		// before = "/home.html/"
 		// after = "/"
 		// So we use the shorter one from before and after as the final name.
 		if len(after) < len(before) {
+			page.addAlias(page.Name)
 			page.Name = after
+			g.emitPage(page)
+		} else {
+			page.addAlias(after)
 		}
-
-		page.addAlias(after)
-		PageMap[after] = page
+		page.addRedirect(before, after)
+		g.pageMap[after] = page
+	} else if page != afterPage {
+		// Both pages exist, keep the older one so the ids of the home page
+		// and most links stay the same.
+		if page.Id < afterPage.Id {
+			g.mergePage(afterPage, page)
+		} else {
+			g.mergePage(page, afterPage)
+			page = afterPage
+		}
+		page.addRedirect(before, after)
 	} else {
-		i := page.Id
-		// Delete the before page (i-th) because the after page already exists.
-		PageList = append(PageList[: i], PageList[i + 1 :]...)
-		afterPage.addAlias(before)
-		PageMap[before] = page
+		page.addRedirect(before, after)
+	}
+}
+
+// mergePage merges from into to: the names and links of from are moved to to,
+// and the links to from are rewritten to to.
+func (g *Graph) mergePage(from *Page, to *Page) {
+	util.LogPrintf("Merge page: %s into %s", from.Name, to.Name)
+	if len(g.listeners) != 0 {
+		g.emit(Event{Type: EventRemove, Id: from.Id, Target: to.Id})
+	}
+
+	if len(from.Name) < len(to.Name) {
+		to.Name, from.Name = from.Name, to.Name
+		g.pageMap[to.Name] = to
+		g.emitPage(to)
+	}
+	for _, name := range append([]string{from.Name}, from.Aliases...) {
+		to.addAlias(name)
+		g.pageMap[name] = to
+	}
+
+	for target, count := range from.Links {
+		if target != to.Id {
+			to.Links[target] += count
+			g.emitLink(to, target)
+		}
+	}
+	to.addHits(from.Hits, from.FirstSeen, from.LastSeen)
+	for status, count := range from.Statuses {
+		to.addStatus(status, count)
+	}
+	for _, r := range from.Redirects {
+		to.addRedirect(r.From, r.To)
+	}
+
+	for _, p := range g.pageList {
+		if p == nil {
+			continue
+		}
+		if count, ok := p.Links[from.Id]; ok {
+			delete(p.Links, from.Id)
+			if p != to {
+				p.Links[to.Id] += count
+				g.emitLink(p, to.Id)
+			}
+		}
+	}
+
+	g.pageList[from.Id] = nil
+}
+
+// setName makes name the name of p, and the old name an alias.
+func (g *Graph) setName(p *Page, name string) {
+	if p.Name == name {
+		return
+	}
+
+	aliases := []string{}
+	for _, alias := range p.Aliases {
+		if alias != name {
+			aliases = append(aliases, alias)
+		}
+	}
+	p.Aliases = append(aliases, p.Name)
+	p.Name = name
+	g.pageMap[name] = p
+	g.emitPage(p)
+}
+
+// RenamePages renames each page to rename(name), e.g., with a wildcard rule
+// learned after the page was added. The pages renamed to the same name are
+// merged into the oldest one, and the old names stay as aliases.
+func (g *Graph) RenamePages(rename func(name string) string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, page := range g.pageList {
+		if page == nil {
+			continue
+		}
+		name := rename(page.Name)
+		if name == page.Name {
+			continue
+		}
+
+		other, ok := g.pageMap[name]
+		if ok && other != page {
+			if other.Id < page.Id {
+				g.mergePage(page, other)
+				page = other
+			} else {
+				g.mergePage(other, page)
+			}
+		}
+		g.setName(page, name)
 	}
 }
 
-func HasPage(name string) bool {
-	_, ok := PageMap[name]
+// AddHit adds a request at t answered with the HTTP status to the page of
+// name, the page is added if it is new. The status is not counted if it is 0.
+func (g *Graph) AddHit(name string, t time.Time, status int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	page := g.getOrAddPage(name)
+	page.addHits(1, t, t)
+	if status != 0 {
+		page.addStatus(status, 1)
+	}
+}
+
+func (g *Graph) HasPage(name string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	_, ok := g.pageMap[name]
 	return ok
 }
 
-func AddLink(sPage string, tPage string) {
-	PageMap[sPage].addLink(tPage)
+// AddLink adds a link from sPage to tPage, and returns true if tPage is new.
+func (g *Graph) AddLink(sPage string, tPage string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.addLink(g.getOrAddPage(sPage), tPage)
 }
@@ -0,0 +1,193 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testPageIds(t *testing.T, g *Graph) {
+	t.Helper()
+	for _, page := range g.Pages() {
+		if p := g.GetPage(page.Id); p == nil || p.Name != page.Name {
+			t.Errorf("GetPage(%d) is not the page %s", page.Id, page.Name)
+		}
+		for target := range page.Links {
+			if g.GetPage(target) == nil {
+				t.Errorf("page %s links to the missing page %d", page.Name, target)
+			}
+		}
+	}
+}
+
+func TestAddRedirectPage(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddLink("/", "/a/")
+	g.AddLink("/", "/home/")
+	g.AddLink("/a/", "/b/")
+	g.AddLink("/b/", "/home/")
+	g.AddLink("/home/", "/a/")
+
+	// "/home/" is merged into "/", the later page "/b/" keeps its id.
+	g.AddRedirectPage("/", "/home/")
+	testPageIds(t, g)
+
+	home := g.GetPageByName("/home/")
+	if home == nil || home.Id != 0 || home.Name != "/" || !reflect.DeepEqual(home.Aliases, []string{"/home/"}) {
+		t.Fatalf("GetPageByName(/home/) = %+v, supposed to be the page 0 named / with the alias /home/", home)
+	}
+	if !reflect.DeepEqual(home.Links, map[int]int{1: 2}) {
+		t.Errorf("home.Links = %v, supposed to be map[1:2]", home.Links)
+	}
+	if b := g.GetPageByName("/b/"); b.Id != 3 || !reflect.DeepEqual(b.Links, map[int]int{0: 1}) {
+		t.Errorf("/b/ = %+v, supposed to be the page 3 linking to the page 0", b)
+	}
+	if len(g.Pages()) != 3 {
+		t.Errorf("len(Pages()) = %d, supposed to be 3", len(g.Pages()))
+	}
+}
+
+func TestAddRedirectPageMissing(t *testing.T) {
+	g := NewGraph()
+	g.AddRedirectPage("/old-page/", "/new/")
+	g.AddRedirectPage("/older/", "/new/")
+	g.AddLink("/missing/", "/older/")
+	testPageIds(t, g)
+
+	page := g.GetPageByName("/older/")
+	if page == nil || page.Name != "/new/" || !reflect.DeepEqual(page.Aliases, []string{"/old-page/", "/older/"}) {
+		t.Errorf("GetPageByName(/older/) = %+v, supposed to be /new/ with the aliases /old-page/ and /older/", page)
+	}
+}
+
+func TestRenamePages(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddLink("/", "/author/alice/")
+	g.AddLink("/", "/author/bob/")
+	g.AddLink("/author/alice/", "/author/bob/")
+	g.AddLink("/author/bob/", "/about/")
+	g.AddLink("/", "/author/*/")
+
+	g.RenamePages(func(name string) string {
+		if strings.HasPrefix(name, "/author/") {
+			return "/author/*/"
+		}
+		return name
+	})
+	testPageIds(t, g)
+
+	author := g.GetPageByName("/author/bob/")
+	if author == nil || author.Id != 1 || author.Name != "/author/*/" || !reflect.DeepEqual(author.Aliases, []string{"/author/alice/", "/author/bob/"}) {
+		t.Fatalf("GetPageByName(/author/bob/) = %+v, supposed to be the page 1 named /author/*/ with the aliases /author/alice/ and /author/bob/", author)
+	}
+	if home := g.GetPageByName("/"); !reflect.DeepEqual(home.Links, map[int]int{1: 3}) {
+		t.Errorf("home.Links = %v, supposed to be map[1:3]", home.Links)
+	}
+	if about := g.GetPageByName("/about/"); !reflect.DeepEqual(author.Links, map[int]int{about.Id: 1}) {
+		t.Errorf("author.Links = %v, supposed to be map[%d:1]", author.Links, about.Id)
+	}
+	if len(g.Pages()) != 3 {
+		t.Errorf("len(Pages()) = %d, supposed to be 3", len(g.Pages()))
+	}
+	if name := g.GetName("/author/alice/"); name != "/author/*/" {
+		t.Errorf("GetName(/author/alice/) = %s, supposed to be /author/*/", name)
+	}
+	if name := g.GetName("/missing/"); name != "/missing/" {
+		t.Errorf("GetName(/missing/) = %s, supposed to be /missing/", name)
+	}
+}
+
+func TestConcurrentAddLink(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+
+	var wg sync.WaitGroup
+	news := make(chan bool, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			news <- g.AddLink("/", fmt.Sprintf("/page/%d/", i%10))
+			g.Pages()
+		}(i)
+	}
+	wg.Wait()
+	close(news)
+
+	count := 0
+	for isNew := range news {
+		if isNew {
+			count++
+		}
+	}
+	if count != 10 {
+		t.Errorf("%d links added new pages, supposed to be 10", count)
+	}
+	if links := g.GetPage(0).Links; len(links) != 10 {
+		t.Errorf("len(Links) = %d, supposed to be 10", len(links))
+	}
+}
+
+func TestAddHit(t *testing.T) {
+	t0 := time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC)
+
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddHit("/a/", t0.Add(time.Hour), 200)
+	g.AddHit("/a/", t0.Add(time.Minute), 0)
+	g.AddHit("/home/", t0, 301)
+	g.AddHit("/home/", t0.Add(2*time.Hour), 200)
+	g.AddRedirectPage("/", "/home/")
+
+	if a := g.GetPageByName("/a/"); a.Hits != 2 || !a.FirstSeen.Equal(t0.Add(time.Minute)) || !a.LastSeen.Equal(t0.Add(time.Hour)) {
+		t.Errorf("/a/ = %+v, supposed to have 2 hits from %v to %v", a, t0.Add(time.Minute), t0.Add(time.Hour))
+	}
+	if home := g.GetPageByName("/"); home.Hits != 2 || !home.FirstSeen.Equal(t0) || !home.LastSeen.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("/ = %+v, supposed to have the 2 hits of /home/ from %v to %v", home, t0, t0.Add(2*time.Hour))
+	}
+	if a, home := g.GetPageByName("/a/"), g.GetPageByName("/"); !reflect.DeepEqual(a.Statuses, map[int]int{200: 1}) || !reflect.DeepEqual(home.Statuses, map[int]int{200: 1, 301: 1}) {
+		t.Errorf("/a/, / statuses = %v, %v, supposed to be map[200:1], map[200:1 301:1]", a.Statuses, home.Statuses)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddRedirectPage("/", "/home/")
+	g.AddRedirectPage("/", "/home/")
+	g.AddLink("/", "/a/")
+	g.AddLink("/", "/b/")
+	g.AddRedirectPage("/b/", "/a/")
+	g.AddRedirectPage("/c/", "/c/")
+
+	home := g.GetPageByName("/")
+	if !reflect.DeepEqual(home.Redirects, []Redirect{{"/", "/home/"}}) {
+		t.Errorf("/ redirects = %v, supposed to be [{/ /home/}]", home.Redirects)
+	}
+	a := g.GetPageByName("/b/")
+	if a.Name != "/a/" || !reflect.DeepEqual(a.Redirects, []Redirect{{"/b/", "/a/"}}) {
+		t.Errorf("/b/ = %+v, supposed to be /a/ redirected from /b/", a)
+	}
+	if c := g.GetPageByName("/c/"); c == nil || len(c.Redirects) != 0 {
+		t.Errorf("/c/ = %+v, supposed to be a page without redirects", c)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// graphJson is the JSON of a graph, the holes of the merged pages are null so
+// the pages keep their ids.
+type graphJson struct {
+	Pages []*Page `json:"pages"`
+}
+
+// MarshalJSON encodes the pages of the graph with their ids, aliases, hits and
+// redirects, so the graph can be saved and loaded with UnmarshalJSON().
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return json.Marshal(graphJson{g.pageList})
+}
+
+// UnmarshalJSON replaces the pages of the graph with the ones encoded by
+// MarshalJSON(). The listeners are kept but not called.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	gj := graphJson{}
+	if err := json.Unmarshal(data, &gj); err != nil {
+		return err
+	}
+	return g.setPages(gj.Pages)
+}
+
+// NewGraphOfPages creates a graph of the pages at their ids, like the pages
+// read from a saved graph, the missing ids are the holes of merged pages.
+func NewGraphOfPages(pages []*Page) (*Graph, error) {
+	list := []*Page{}
+	for _, page := range pages {
+		if page.Id < 0 {
+			return nil, fmt.Errorf("page %s has the negative id %d", page.Name, page.Id)
+		}
+		for len(list) <= page.Id {
+			list = append(list, nil)
+		}
+		if list[page.Id] != nil {
+			return nil, fmt.Errorf("pages %s and %s have the same id %d", list[page.Id].Name, page.Name, page.Id)
+		}
+		list[page.Id] = page.copy()
+	}
+
+	g := NewGraph()
+	if err := g.setPages(list); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// setPages replaces the pages of the graph with pages, where each page is at
+// its id.
+func (g *Graph) setPages(pages []*Page) error {
+	pageMap := make(map[string]*Page)
+	for id, page := range pages {
+		if page == nil {
+			continue
+		}
+		if page.Id != id {
+			return fmt.Errorf("page %s has the id %d at %d", page.Name, page.Id, id)
+		}
+		if page.Links == nil {
+			page.Links = make(map[int]int)
+		}
+		for target := range page.Links {
+			if target < 0 || target >= len(pages) || pages[target] == nil {
+				return fmt.Errorf("page %s links to the missing page %d", page.Name, target)
+			}
+		}
+		for _, name := range append([]string{page.Name}, page.Aliases...) {
+			if p, ok := pageMap[name]; ok && p != page {
+				return fmt.Errorf("pages %d and %d have the same name %s", p.Id, page.Id, name)
+			}
+			pageMap[name] = page
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pageList = pages
+	if g.pageList == nil {
+		g.pageList = []*Page{}
+	}
+	g.pageMap = pageMap
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGraphJson(t *testing.T) {
+	t0 := time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC)
+
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddLink("/", "/a/")
+	g.AddLink("/", "/home/")
+	g.AddHit("/a/", t0, 200)
+	g.AddRedirectPage("/", "/home/")
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGraph()
+	if err := json.Unmarshal(data, g2); err != nil {
+		t.Fatal(err)
+	}
+	testPageIds(t, g2)
+
+	if !reflect.DeepEqual(g2.Pages(), g.Pages()) {
+		t.Errorf("Pages() = %+v, supposed to be %+v", g2.Pages(), g.Pages())
+	}
+	if home := g2.GetPageByName("/home/"); home == nil || home.Id != 0 {
+		t.Errorf("GetPageByName(/home/) = %+v, supposed to be the page 0 by its alias", home)
+	}
+	// The new pages are added after the hole of the merged page.
+	g2.AddLink("/", "/b/")
+	if b := g2.GetPageByName("/b/"); b.Id != 3 {
+		t.Errorf("/b/ id = %d, supposed to be 3", b.Id)
+	}
+
+	bad := []string{
+		`{"pages": [{"id": 1, "name": "/"}]}`,
+		`{"pages": [{"id": 0, "name": "/", "links": {"1": 1}}, null]}`,
+		`{"pages": {}}`,
+	}
+	for _, data := range bad {
+		if err := json.Unmarshal([]byte(data), NewGraph()); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, supposed to fail", data)
+		}
+	}
+}
+
+func TestNewGraphOfPages(t *testing.T) {
+	pages := []*Page{
+		{Id: 2, Name: "/b/", Aliases: []string{"/c/"}},
+		{Id: 0, Name: "/", Links: map[int]int{2: 3}},
+	}
+	g, err := NewGraphOfPages(pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPageIds(t, g)
+
+	if g.GetPage(1) != nil || len(g.Pages()) != 2 {
+		t.Errorf("Pages() = %+v, supposed to be the pages 0 and 2", g.Pages())
+	}
+	if b := g.GetPageByName("/c/"); b == nil || b.Id != 2 {
+		t.Errorf("GetPageByName(/c/) = %+v, supposed to be the page 2 by its alias", b)
+	}
+	if b := g.GetPage(2); b.Links == nil {
+		t.Errorf("/b/ has nil links, supposed to be an empty map")
+	}
+	g.AddLink("/b/", "/d/")
+	if d := g.GetPageByName("/d/"); d.Id != 3 {
+		t.Errorf("/d/ id = %d, supposed to be 3", d.Id)
+	}
+	// The pages are copied.
+	pages[1].Links[2] = 4
+	if home := g.GetPage(0); home.Links[2] != 3 {
+		t.Errorf("home.Links = %v, supposed to be map[2:3]", home.Links)
+	}
+
+	bad := [][]*Page{
+		{{Id: -1, Name: "/"}},
+		{{Id: 0, Name: "/"}, {Id: 0, Name: "/a/"}},
+		{{Id: 0, Name: "/"}, {Id: 1, Name: "/"}},
+		{{Id: 0, Name: "/", Links: map[int]int{1: 1}}},
+	}
+	for _, pages := range bad {
+		if _, err := NewGraphOfPages(pages); err == nil {
+			t.Errorf("NewGraphOfPages(%+v) succeeded, supposed to fail", pages)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"sort"
+)
+
+type edge struct {
+	source int
+	target int
+	weight int
+}
+
+// getScores gets the score of each page to keep in Prune(): its hits and the
+// weights of its links in and out.
+func (g *Graph) getScores() map[int]int {
+	scores := make(map[int]int)
+	for _, page := range g.pageList {
+		if page == nil {
+			continue
+		}
+
+		scores[page.Id] += page.Hits
+		for target, count := range page.Links {
+			scores[page.Id] += count
+			scores[target] += count
+		}
+	}
+	return scores
+}
+
+// Prune returns a copy of the graph with at most maxNodes pages and maxEdges
+// links, and without the links lighter than minWeight, 0 means no limit. The
+// home page and the pages of the most hits and heaviest links are kept, then
+// the heaviest links between them, and the pages left without links are
+// dropped. The pages keep their ids.
+func (g *Graph) Prune(maxNodes int, maxEdges int, minWeight int) *Graph {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	scores := g.getScores()
+	pages := []*Page{}
+	for _, page := range g.pageList {
+		if page != nil {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		if pages[i].Name == "/" || pages[j].Name == "/" {
+			return pages[i].Name == "/"
+		}
+		return scores[pages[i].Id] > scores[pages[j].Id]
+	})
+	if maxNodes > 0 && len(pages) > maxNodes {
+		pages = pages[:maxNodes]
+	}
+
+	kept := make(map[int]bool)
+	for _, page := range pages {
+		kept[page.Id] = true
+	}
+	edges := []edge{}
+	for _, page := range pages {
+		for target, count := range page.Links {
+			if kept[target] && count >= minWeight {
+				edges = append(edges, edge{page.Id, target, count})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].weight != edges[j].weight {
+			return edges[i].weight > edges[j].weight
+		}
+		if edges[i].source != edges[j].source {
+			return edges[i].source < edges[j].source
+		}
+		return edges[i].target < edges[j].target
+	})
+	if maxEdges > 0 && len(edges) > maxEdges {
+		edges = edges[:maxEdges]
+	}
+
+	pg := NewGraph()
+	pg.pageList = make([]*Page, len(g.pageList))
+	for _, page := range pages {
+		p := page.copy()
+		p.Links = make(map[int]int)
+		pg.pageList[p.Id] = p
+	}
+	linked := make(map[int]bool)
+	for _, e := range edges {
+		pg.pageList[e.source].Links[e.target] = e.weight
+		linked[e.source] = true
+		linked[e.target] = true
+	}
+
+	for _, page := range pages {
+		p := pg.pageList[page.Id]
+		// The page had links but they are all pruned.
+		if page.Name != "/" && !linked[page.Id] && scores[page.Id] != page.Hits {
+			pg.pageList[page.Id] = nil
+			continue
+		}
+
+		pg.pageMap[p.Name] = p
+		for _, alias := range p.Aliases {
+			pg.pageMap[alias] = p
+		}
+	}
+	return pg
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getNames(g *Graph) []string {
+	names := []string{}
+	for _, page := range g.Pages() {
+		names = append(names, page.Name)
+	}
+	return names
+}
+
+func TestPrune(t *testing.T) {
+	g := NewGraph()
+	g.AddPage("/")
+	g.AddRedirectPage("/", "/home/")
+	for i := 0; i < 3; i++ {
+		g.AddLink("/", "/a/")
+		g.AddLink("/a/", "/b/")
+	}
+	g.AddLink("/", "/b/")
+	g.AddLink("/", "/c/")
+	g.AddLink("/c/", "/d/")
+	g.AddHit("/e/", time.Now(), 200)
+
+	p := g.Prune(0, 0, 0)
+	if !reflect.DeepEqual(p.Pages(), g.Pages()) {
+		t.Errorf("Prune(0, 0, 0) = %v, supposed to be the same as %v", p.Pages(), g.Pages())
+	}
+
+	// "/c/" and "/d/" lose their links, "/e/" never had any.
+	p = g.Prune(0, 0, 2)
+	testPageIds(t, p)
+	if names := getNames(p); !reflect.DeepEqual(names, []string{"/", "/a/", "/b/", "/e/"}) {
+		t.Errorf("Prune(0, 0, 2) pages = %v, supposed to be /, /a/, /b/ and /e/", names)
+	}
+	if home := p.GetPageByName("/home/"); home == nil || !reflect.DeepEqual(home.Links, map[int]int{1: 3}) {
+		t.Errorf("Prune(0, 0, 2) home = %+v, supposed to link to /a/ only", home)
+	}
+
+	p = g.Prune(3, 0, 0)
+	testPageIds(t, p)
+	if names := getNames(p); !reflect.DeepEqual(names, []string{"/", "/a/", "/b/"}) {
+		t.Errorf("Prune(3, 0, 0) pages = %v, supposed to be /, /a/ and /b/", names)
+	}
+
+	p = g.Prune(0, 2, 0)
+	testPageIds(t, p)
+	if a := p.GetPageByName("/a/"); a == nil || a.Hits != 0 || !reflect.DeepEqual(a.Links, map[int]int{2: 3}) {
+		t.Errorf("Prune(0, 2, 0) /a/ = %+v, supposed to link to /b/", a)
+	}
+	if names := getNames(p); !reflect.DeepEqual(names, []string{"/", "/a/", "/b/", "/e/"}) {
+		t.Errorf("Prune(0, 2, 0) pages = %v, supposed to be /, /a/, /b/ and /e/", names)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hsluoyz/logdance/ingest"
+	"github.com/hsluoyz/logdance/logs"
+	"github.com/hsluoyz/logdance/render"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// ingestFlags are the flags shared by the commands reading access logs.
+type ingestFlags struct {
+	output         *outputFlags
+	host           *string
+	format         *string
+	formats        *string
+	sessions       *bool
+	sessionKey     *string
+	sessionTimeout *time.Duration
+	rules          *string
+	logFile        *string
+}
+
+func addIngestFlags(fs *flag.FlagSet) *ingestFlags {
+	f := ingestFlags{}
+	f.output = addOutputFlags(fs)
+	f.host = fs.String("host", "", "host of the site like www.example.com, the referers of other hosts are ignored")
+	f.format = fs.String("format", "", fmt.Sprintf("log format, one of %v, detected from the first lines of each log if empty", logs.GetFormatNames()))
+	f.formats = fs.String("formats", "", "JSON file of the custom log formats in Nginx log_format syntax")
+	f.sessions = fs.Bool("sessions", false, "derive the links from the consecutive hits of a session when the referer is missing")
+	f.sessionKey = fs.String("session-key", "", "variable identifying a client like cookie_sid or http_x_user_id, the client IP and user agent by default")
+	f.sessionTimeout = fs.Duration("session-timeout", 30*time.Minute, "inactivity timeout of a session")
+	f.rules = fs.String("rules", "", "JSON file of the pattern rules")
+	f.logFile = fs.String("log", "page.log", "log file, empty to disable logging")
+	fs.IntVar(&verbosity, "v", 1, "verbosity: 0 quiet, 1 summary")
+	return &f
+}
+
+// newBuilder sets up the log and creates a started builder from the flags.
+func (f *ingestFlags) newBuilder(cmd string) (*ingest.Builder, error) {
+	if err := f.output.check(); err != nil {
+		return nil, err
+	}
+	if err := util.SetLogFile(*f.logFile); err != nil {
+		return nil, err
+	}
+	if *f.host == "" {
+		fmt.Fprintf(os.Stderr, "logdance %s: no -host, the referers are ignored\n", cmd)
+	}
+
+	b := ingest.NewBuilder(*f.host)
+	n, err := newNormalizer(*f.rules)
+	if err != nil {
+		return nil, err
+	}
+	b.Normalizer = n
+	if *f.formats != "" {
+		if err := logs.LoadFormats(*f.formats); err != nil {
+			return nil, err
+		}
+	}
+	if *f.format != "" {
+		format, err := logs.GetFormat(*f.format)
+		if err != nil {
+			return nil, err
+		}
+		b.Format = format
+	}
+	if *f.sessions {
+		b.Sessions = ingest.NewSessionizer(*f.sessionKey, *f.sessionTimeout)
+	}
+	b.Start()
+	return b, nil
+}
+
+func runIngest(args []string) error {
+	fs := newFlagSet("ingest")
+	f := addIngestFlags(fs)
+	parallel := fs.Int("parallel", 1, "number of the logs parsed at the same time")
+	start := fs.String("start", "", "skip the records before the time like 2006-01-02 or 2006-01-02T15:04 in the local time, or in RFC 3339")
+	end := fs.String("end", "", "skip the records from the time, in the format of -start")
+	bucket := fs.String("bucket", "", "split the records into the windows of hour, day or a duration like 15m, and also write the graph of each window")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	since, err := parseTime(*start)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(*end)
+	if err != nil {
+		return err
+	}
+	bucketSize, err := parseBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	paths, err := ingest.ExpandInputs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	b, err := f.newBuilder("ingest")
+	if err != nil {
+		return err
+	}
+	b.Parallel = *parallel
+	b.Since, b.Until, b.Bucket = since, until, bucketSize
+
+	if err := b.SortLogs(paths); err != nil {
+		return err
+	}
+	if err := b.IngestFiles(paths); err != nil {
+		return err
+	}
+
+	if verbosity >= 1 {
+		fmt.Printf("%d logs, %d lines, %d errors, %d pages\n", len(paths), b.Lines, b.Errors, len(b.Graph.Pages()))
+	}
+	if b.Bucket == 0 {
+		return f.output.generate(b.Graph)
+	}
+	return generateWindows(b, f.output)
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseTime parses the time of -start and -end, the times without zones are in
+// the local time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not like 2006-01-02, 2006-01-02T15:04 or 2006-01-02T15:04:05Z07:00", value)
+}
+
+func parseBucket(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%q is not hour, day or a positive duration", value)
+	}
+	return d, nil
+}
+
+// getWindowPath gets the path of the graph of the window starting at start,
+// e.g., "webgraph.json" -> "webgraph-20060102T1504.json".
+func getWindowPath(path string, start time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + start.Format("20060102T1504") + ext
+}
+
+// generateWindows writes the graph of each window of b, and the combined graph
+// with the counts of the links in every window.
+func generateWindows(b *ingest.Builder, output *outputFlags) error {
+	format := output.getFormat()
+	windows := make([]*render.Window, 0, len(b.Windows))
+	for _, w := range b.Windows {
+		if err := render.Generate(w.Graph, getWindowPath(*output.path, w.Start), format.Name, &output.opts); err != nil {
+			return err
+		}
+		windows = append(windows, &render.Window{Start: w.Start, End: w.End, Graph: w.Graph})
+	}
+
+	if verbosity >= 1 {
+		fmt.Printf("%d windows\n", len(windows))
+	}
+	// Only the JSON has the counts of the links in the windows.
+	if format.Name != "json" {
+		return output.generate(b.Graph)
+	}
+	return render.GenerateTimelineJson(b.Graph, windows, *output.path, &output.opts)
+}
+
+func runTail(args []string) error {
+	fs := newFlagSet("tail")
+	f := addIngestFlags(fs)
+	interval := fs.Duration("interval", 5*time.Second, "interval to write the graph")
+	fromStart := fs.Bool("from-start", false, "read the existing lines of the log first")
+	serve := fs.String("serve", "", "address like :8080 to serve the visualisation of the live graph")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	b, err := f.newBuilder("tail")
+	if err != nil {
+		return err
+	}
+
+	if *serve != "" {
+		s, err := startServer(*serve)
+		if err != nil {
+			return err
+		}
+		s.SetGraph(b.Graph)
+	}
+
+	t := ingest.NewTailer(fs.Arg(0))
+	t.FromStart = *fromStart
+	lr := b.NewLineReader()
+
+	// Stop on Ctrl-C and write the final graph.
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	last, lines := time.Now(), -1
+	generate := func() error {
+		if b.Lines == lines {
+			return nil
+		}
+		if verbosity >= 1 {
+			fmt.Printf("%s: %d lines, %d errors, %d pages\n", time.Now().Format(time.RFC3339), b.Lines, b.Errors, len(b.Graph.Pages()))
+		}
+		last, lines = time.Now(), b.Lines
+		return f.output.generate(b.Graph)
+	}
+
+	err = t.Follow(stop, lr.Add, func() error {
+		// Detect the format from the lines so far if the log is slow.
+		if err := lr.Flush(); err != nil {
+			return err
+		}
+		if time.Since(last) < *interval {
+			return nil
+		}
+		return generate()
+	})
+	if err != nil {
+		return err
+	}
+	return generate()
+}
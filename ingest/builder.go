@@ -0,0 +1,302 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/logs"
+	"github.com/hsluoyz/logdance/pattern"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// Builder builds the page graph of a site from its access log records: each
+// requested page is a node and each referer -> page is a link.
+type Builder struct {
+	Graph      *graph.Graph
+	Normalizer *pattern.Normalizer
+
+	// Host is the host of the site like "www.example.com", only the
+	// referers of the site become links.
+	Host string
+	// SkipAssets skips the requests to the non-HTML files like
+	// "/images/logo.png".
+	SkipAssets bool
+	// Sessions derives the links from the consecutive hits of a session when
+	// the referer is missing, the links are by referer only if it is nil.
+	Sessions *Sessionizer
+	// Format is the format of the logs, it is detected from the first lines
+	// of each log if nil.
+	Format *logs.Format
+	// Since and Until skip the records out of [Since, Until), the zero times
+	// are not limits.
+	Since time.Time
+	Until time.Time
+	// Bucket splits the records into the windows of Bucket like time.Hour,
+	// each window has its own graph besides Graph. The windows without
+	// records are omitted.
+	Bucket  time.Duration
+	Windows []*Window
+
+	// Parallel is the number of the logs read and parsed at the same time by
+	// IngestFiles, the records are still added in the order of the logs.
+	Parallel int
+
+	// Lines is the number of the lines read, Errors is the number of the
+	// lines failed to parse.
+	Lines  int
+	Errors int
+
+	windowMap map[int64]*Window
+
+	// ruleNormalizer is the normalizer renamePages listens to by the id
+	// ruleListener.
+	ruleNormalizer *pattern.Normalizer
+	ruleListener   int
+}
+
+// NewBuilder creates a builder of host with an empty graph and a default
+// normalizer.
+func NewBuilder(host string) *Builder {
+	b := Builder{}
+	b.Graph = graph.NewGraph()
+	b.Normalizer = pattern.NewNormalizer()
+	b.Host = host
+	b.SkipAssets = true
+	return &b
+}
+
+// Start selects the rules of the host and adds the home page, it is called
+// before adding any record.
+func (b *Builder) Start() {
+	b.Normalizer.GenerateCustomRe(b.Host)
+	if b.ruleNormalizer != nil {
+		b.ruleNormalizer.RemoveRuleListener(b.ruleListener)
+	}
+	b.ruleNormalizer = b.Normalizer
+	b.ruleListener = b.Normalizer.AddRuleListener(b.renamePages)
+	b.Graph.AddPage("/")
+}
+
+// renamePages renames the pages added before the inferred rule r in the graph
+// and the windows.
+func (b *Builder) renamePages(r *pattern.Rule) {
+	b.Graph.RenamePages(r.Apply)
+	for _, w := range b.Windows {
+		w.Graph.RenamePages(r.Apply)
+	}
+}
+
+// getPath gets the path like the crawler does, e.g.,
+// "/page" -> "/page/", "/search/?q=go" -> "/search?q=go/".
+func getPath(uri string) string {
+	path := uri
+	if i := strings.Index(uri, "?"); i != -1 {
+		path = strings.TrimRight(uri[:i], "/") + uri[i:]
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
+
+func isSameHost(a string, b string) bool {
+	return strings.TrimPrefix(a, "www.") == strings.TrimPrefix(b, "www.")
+}
+
+// getRefererPath returns the path of the referer if it is a page of the site,
+// or "" otherwise.
+func (b *Builder) getRefererPath(referer string) string {
+	if referer == "" || b.Host == "" {
+		return ""
+	}
+
+	u, err := url.Parse(referer)
+	if err != nil || !isSameHost(u.Hostname(), b.Host) {
+		return ""
+	}
+
+	uri := u.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	if u.RawQuery != "" {
+		uri += "?" + u.RawQuery
+	}
+	return getPath(uri)
+}
+
+// isPage tells whether rec is a request to a page.
+func (b *Builder) isPage(rec *logs.Record) bool {
+	if rec.Method != "GET" {
+		return false
+	}
+
+	path := rec.Path
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+	return !b.SkipAssets || pattern.IsHtml(path)
+}
+
+// Add adds the page of rec and the link from its referer, or else from the
+// previous page of its session, to the graph and the graph of its window.
+func (b *Builder) Add(rec *logs.Record) {
+	if !b.isPage(rec) || !b.inRange(rec.Time) {
+		return
+	}
+
+	tPattern := b.Normalizer.GetPattern(getPath(rec.Path))
+	b.Graph.AddHit(tPattern, rec.Time, rec.Status)
+	w := b.getWindow(rec.Time)
+	if w != nil {
+		w.Graph.AddHit(tPattern, rec.Time, rec.Status)
+	}
+
+	sPattern := ""
+	if source := b.getRefererPath(rec.Referer); source != "" {
+		sPattern = b.Normalizer.GetPattern(source)
+	}
+	if b.Sessions != nil {
+		prev := b.Sessions.Next(rec, tPattern)
+		// An external referer starts a new visit of the site.
+		if sPattern == "" && rec.Referer == "" {
+			sPattern = prev
+		}
+	}
+
+	if sPattern != "" && sPattern != tPattern {
+		b.Graph.AddLink(sPattern, tPattern)
+		if w != nil {
+			w.Graph.AddLink(sPattern, tPattern)
+		}
+	}
+}
+
+// addRecord adds the result of parsing a line, the lines failed to parse are
+// logged and skipped.
+func (b *Builder) addRecord(rec *logs.Record, err error) {
+	b.Lines++
+
+	if err != nil {
+		b.Errors++
+		util.LogPrint(err)
+		return
+	}
+	if rec != nil {
+		b.Add(rec)
+	}
+}
+
+// detectLines is the number of the first lines to detect the log format.
+const detectLines = 10
+
+// LineReader adds the lines of a log to a builder one by one, the format is
+// detected from the first lines if the builder has no format.
+type LineReader struct {
+	p       logs.Parser
+	pending []string
+	handle  func(rec *logs.Record, err error)
+}
+
+// NewLineReader creates a line reader of a log for b.
+func (b *Builder) NewLineReader() *LineReader {
+	return newLineReader(b.Format, b.addRecord)
+}
+
+// newLineReader creates a line reader of a log in format, or in the detected
+// format if it is nil, which passes the parsed lines to handle.
+func newLineReader(format *logs.Format, handle func(rec *logs.Record, err error)) *LineReader {
+	lr := LineReader{}
+	if format != nil {
+		lr.p = format.NewParser()
+	}
+	lr.handle = handle
+	return &lr
+}
+
+func (lr *LineReader) addLine(line string) {
+	lr.handle(lr.p.Parse(line))
+}
+
+// Add adds a line, the first lines are kept until the format is detected.
+func (lr *LineReader) Add(line string) error {
+	if line == "" {
+		return nil
+	}
+
+	if lr.p != nil {
+		lr.addLine(line)
+		return nil
+	}
+
+	lr.pending = append(lr.pending, line)
+	if len(lr.pending) == detectLines {
+		return lr.Flush()
+	}
+	return nil
+}
+
+// Flush detects the format from the kept lines if there are fewer than
+// detectLines of them, and adds them.
+func (lr *LineReader) Flush() error {
+	if lr.p != nil || len(lr.pending) == 0 {
+		return nil
+	}
+
+	f, err := logs.DetectFormat(lr.pending)
+	if err != nil {
+		return err
+	}
+	util.LogPrint("Detected log format: ", f.Name)
+
+	lr.p = f.NewParser()
+	for _, line := range lr.pending {
+		lr.addLine(line)
+	}
+	lr.pending = nil
+	return nil
+}
+
+// newScanner creates a line scanner of r which allows the long lines of the
+// JSON logs.
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
+
+// Ingest reads the access log from r line by line and adds its records, the
+// lines failed to parse are logged and skipped.
+func (b *Builder) Ingest(r io.Reader) error {
+	lr := b.NewLineReader()
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		if err := lr.Add(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return lr.Flush()
+}
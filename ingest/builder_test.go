@@ -0,0 +1,84 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hsluoyz/logdance/pattern"
+)
+
+const testLog = `1.1.1.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 100 "https://www.google.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:37 -0700] "GET /author/5 HTTP/1.1" 200 100 "https://example.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:38 -0700] "GET /style.css HTTP/1.1" 200 100 "https://example.com/author/5" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:39 -0700] "GET /author/6?tab=1 HTTP/1.1" 200 100 "https://www.example.com/author/5" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:40 -0700] "GET /author/7 HTTP/1.1" 200 100 "https://example.com/" "UA"
+garbage
+`
+
+func TestIngest(t *testing.T) {
+	b := NewBuilder("www.example.com")
+	b.Start()
+	if err := b.Ingest(strings.NewReader(testLog)); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Lines != 6 || b.Errors != 1 {
+		t.Errorf("Lines, Errors = %d, %d, supposed to be 6, 1", b.Lines, b.Errors)
+	}
+
+	home := b.Graph.GetPageByName("/")
+	author := b.Graph.GetPageByName("/author/*/")
+	query := b.Graph.GetPageByName("/author/*?tab=*")
+	if home == nil || author == nil || query == nil || len(b.Graph.Pages()) != 3 {
+		t.Fatalf("Pages() = %v, supposed to be /, /author/*/ and /author/*?tab=*", b.Graph.Pages())
+	}
+	if home.Links[author.Id] != 2 {
+		t.Errorf("/ -> /author/*/ = %d, supposed to be 2", home.Links[author.Id])
+	}
+	if author.Links[query.Id] != 1 {
+		t.Errorf("/author/*/ -> /author/*?tab=* = %d, supposed to be 1", author.Links[query.Id])
+	}
+}
+
+const testInferLog = `1.1.1.1 - - [10/Oct/2000:13:55:36 -0700] "GET /author/alice HTTP/1.1" 200 100 "https://example.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:37 -0700] "GET /author/bob HTTP/1.1" 200 100 "https://example.com/author/alice" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:38 -0700] "GET /author/carol HTTP/1.1" 200 100 "https://example.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:39 -0700] "GET /author/dave HTTP/1.1" 200 100 "https://example.com/" "UA"
+`
+
+func TestIngestInfer(t *testing.T) {
+	b := NewBuilder("example.com")
+	b.Normalizer.SetInferrer(pattern.NewInferrer(3))
+	b.Start()
+	if err := b.Ingest(strings.NewReader(testInferLog)); err != nil {
+		t.Fatal(err)
+	}
+
+	// "/author/alice/" and "/author/bob/" are seen before the rule, they are
+	// merged into "/author/*/" when it is inferred.
+	home := b.Graph.GetPageByName("/")
+	author := b.Graph.GetPageByName("/author/*/")
+	if home == nil || author == nil || len(b.Graph.Pages()) != 2 {
+		t.Fatalf("Pages() = %v, supposed to be / and /author/*/", b.Graph.Pages())
+	}
+	if author.Hits != 4 || home.Links[author.Id] != 3 {
+		t.Errorf("/author/*/ has %d hits and %d links from /, supposed to be 4 and 3", author.Hits, home.Links[author.Id])
+	}
+	if p := b.Graph.GetPageByName("/author/alice/"); p == nil || p.Id != author.Id {
+		t.Errorf("GetPageByName(/author/alice/) = %v, supposed to be /author/*/", p)
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/hsluoyz/logdance/logs"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ExpandInputs expands the globs like "access.log*" and the directories in args
+// into the log files, the files of a directory are taken without its
+// subdirectories and hidden files. "-" is kept for the standard input.
+func ExpandInputs(args []string) ([]string, error) {
+	paths := []string{}
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == "-" {
+			add(arg)
+			continue
+		}
+
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no matching files", arg)
+			}
+		}
+
+		for _, match := range matches {
+			fi, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				add(match)
+				continue
+			}
+
+			files, err := ioutil.ReadDir(match)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				if file.Mode().IsRegular() && !strings.HasPrefix(file.Name(), ".") {
+					add(filepath.Join(match, file.Name()))
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+type logReader struct {
+	io.Reader
+	close func() error
+}
+
+func (r *logReader) Close() error {
+	return r.close()
+}
+
+// OpenLog opens the log at path, "-" is the standard input. The gzip and zstd
+// compressed logs like "access.log.1.gz" are detected by their magic numbers
+// and decompressed.
+func OpenLog(path string) (io.ReadCloser, error) {
+	f := os.Stdin
+	closeFile := func() error { return nil }
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		closeFile = f.Close
+	}
+
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			closeFile()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return &logReader{zr, func() error {
+			zr.Close()
+			return closeFile()
+		}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			closeFile()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return &logReader{zr, func() error {
+			zr.Close()
+			return closeFile()
+		}}, nil
+	}
+	return &logReader{r, closeFile}, nil
+}
+
+// timeLines is the number of the first lines to look for the time of a log.
+const timeLines = 100
+
+// getLogTime gets the time of the first record of the log at path, or its
+// modification time if no record has a time.
+func (b *Builder) getLogTime(path string) (time.Time, error) {
+	f, err := OpenLog(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	t := time.Time{}
+	lr := newLineReader(b.Format, func(rec *logs.Record, err error) {
+		if t.IsZero() && rec != nil {
+			t = rec.Time
+		}
+	})
+
+	scanner := newScanner(f)
+	for i := 0; i < timeLines && t.IsZero() && scanner.Scan(); i++ {
+		if err := lr.Add(scanner.Text()); err != nil {
+			return t, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return t, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := lr.Flush(); err != nil {
+		return t, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if t.IsZero() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return t, err
+		}
+		t = fi.ModTime()
+	}
+	return t, nil
+}
+
+// SortLogs sorts paths from the oldest log to the newest by the times of their
+// first records, so the sessions go on across the rotated logs. The standard
+// input "-" can not be read twice, so it is put last.
+func (b *Builder) SortLogs(paths []string) error {
+	times := make(map[string]time.Time)
+	for _, path := range paths {
+		if path == "-" {
+			continue
+		}
+
+		t, err := b.getLogTime(path)
+		if err != nil {
+			return err
+		}
+		times[path] = t
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i] == "-" || paths[j] == "-" {
+			return paths[j] == "-" && paths[i] != "-"
+		}
+		return times[paths[i]].Before(times[paths[j]])
+	})
+	return nil
+}
+
+// IngestFiles ingests the logs at paths in order, see OpenLog() for the paths.
+func (b *Builder) IngestFiles(paths []string) error {
+	if b.Parallel > 1 && len(paths) > 1 {
+		return b.ingestParallel(paths)
+	}
+
+	for _, path := range paths {
+		if err := b.ingestFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) ingestFile(path string) error {
+	f, err := OpenLog(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := b.Ingest(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// parsedLine is the result of parsing a line.
+type parsedLine struct {
+	rec *logs.Record
+	err error
+}
+
+// parsedLog is a log parsed in the background, err is set before batches is
+// closed.
+type parsedLog struct {
+	batches chan []parsedLine
+	err     error
+}
+
+// batchLines is the number of the lines sent at a time by parseLog().
+const batchLines = 1024
+
+// parseLog parses the log at path and sends the parsed lines to out, until
+// stop is closed.
+func (b *Builder) parseLog(path string, out chan<- []parsedLine, stop <-chan struct{}) error {
+	f, err := OpenLog(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	batch := []parsedLine{}
+	lr := newLineReader(b.Format, func(rec *logs.Record, err error) {
+		batch = append(batch, parsedLine{rec, err})
+	})
+	send := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+
+		select {
+		case out <- batch:
+			batch = make([]parsedLine, 0, batchLines)
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	scanner := newScanner(f)
+	for scanner.Scan() {
+		if err := lr.Add(scanner.Text()); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if len(batch) >= batchLines && !send() {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := lr.Flush(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	send()
+	return nil
+}
+
+// ingestParallel parses b.Parallel logs at the same time, and adds their
+// records to the graph in the order of paths, so the graph is the same as
+// ingesting them one by one.
+func (b *Builder) ingestParallel(paths []string) error {
+	parsed := make([]*parsedLog, len(paths))
+	for i := range parsed {
+		parsed[i] = &parsedLog{batches: make(chan []parsedLine, 16)}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// The logs are started in order, so the log being added always has a
+	// slot and the later ones wait for it.
+	slots := make(chan struct{}, b.Parallel)
+	go func() {
+		for i, path := range paths {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			go func(pl *parsedLog, path string) {
+				defer func() { <-slots }()
+				pl.err = b.parseLog(path, pl.batches, stop)
+				close(pl.batches)
+			}(parsed[i], path)
+		}
+	}()
+
+	for _, pl := range parsed {
+		for batch := range pl.batches {
+			for _, line := range batch {
+				b.addRecord(line.rec, line.err)
+			}
+		}
+		if pl.err != nil {
+			return pl.err
+		}
+	}
+	return nil
+}
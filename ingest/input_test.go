@@ -0,0 +1,173 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func writeLog(t *testing.T, path string, data string) {
+	t.Helper()
+
+	buf := bytes.Buffer{}
+	switch filepath.Ext(path) {
+	case ".gz":
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(data))
+		w.Close()
+	case ".zst":
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+		w.Close()
+	default:
+		buf.WriteString(data)
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenLog(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"access.log", "access.log.1.gz", "access.log.2.zst"} {
+		path := filepath.Join(dir, name)
+		writeLog(t, path, testLog)
+
+		f, err := OpenLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != testLog {
+			t.Errorf("OpenLog(%q) = %q, supposed to be %q", name, data, testLog)
+		}
+	}
+
+	if _, err := OpenLog(filepath.Join(dir, "missing.log")); err == nil {
+		t.Errorf("OpenLog(\"missing.log\") succeeded, supposed to fail")
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log.gz", ".hidden", "sub/c.log"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		writeLog(t, filepath.Join(dir, name), "")
+	}
+
+	testExpandInputs(t, dir, []string{dir}, []string{"a.log", "b.log.gz"})
+	testExpandInputs(t, dir, []string{filepath.Join(dir, "*.log*"), filepath.Join(dir, "a.log")}, []string{"a.log", "b.log.gz"})
+	testExpandInputs(t, dir, []string{filepath.Join(dir, "s*"), "-"}, []string{"sub/c.log", "-"})
+
+	if _, err := ExpandInputs([]string{filepath.Join(dir, "*.txt")}); err == nil {
+		t.Errorf("ExpandInputs(\"*.txt\") succeeded, supposed to fail")
+	}
+}
+
+func testExpandInputs(t *testing.T, dir string, args []string, res []string) {
+	t.Helper()
+
+	paths, err := ExpandInputs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range paths {
+		if path != "-" {
+			paths[i], _ = filepath.Rel(dir, path)
+			paths[i] = filepath.ToSlash(paths[i])
+		}
+	}
+	if !reflect.DeepEqual(paths, res) {
+		t.Errorf("ExpandInputs(%v) = %v, supposed to be %v", args, paths, res)
+	}
+}
+
+// testLogs splits testLog into the rotated logs, the newest first.
+func testLogs(t *testing.T) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	lines := strings.SplitAfter(testLog, "\n")
+	paths := []string{
+		filepath.Join(dir, "access.log"),
+		filepath.Join(dir, "access.log.1.zst"),
+		filepath.Join(dir, "access.log.2.gz"),
+	}
+	writeLog(t, paths[0], strings.Join(lines[4:], ""))
+	writeLog(t, paths[1], strings.Join(lines[2:4], ""))
+	writeLog(t, paths[2], strings.Join(lines[:2], ""))
+	return paths
+}
+
+func TestIngestFiles(t *testing.T) {
+	want := NewBuilder("www.example.com")
+	want.Start()
+	want.Ingest(strings.NewReader(testLog))
+
+	for _, parallel := range []int{1, 2, 3} {
+		paths := testLogs(t)
+
+		b := NewBuilder("www.example.com")
+		b.Parallel = parallel
+		b.Start()
+		if err := b.SortLogs(paths); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(paths[0], ".2.gz") || !strings.HasSuffix(paths[2], "access.log") {
+			t.Errorf("SortLogs() = %v, supposed to be from access.log.2.gz to access.log", paths)
+		}
+		if err := b.IngestFiles(paths); err != nil {
+			t.Fatal(err)
+		}
+
+		if b.Lines != want.Lines || b.Errors != want.Errors {
+			t.Errorf("Parallel %d: Lines, Errors = %d, %d, supposed to be %d, %d", parallel, b.Lines, b.Errors, want.Lines, want.Errors)
+		}
+		if !reflect.DeepEqual(b.Graph.Pages(), want.Graph.Pages()) {
+			t.Errorf("Parallel %d: Pages() = %v, supposed to be %v", parallel, b.Graph.Pages(), want.Graph.Pages())
+		}
+	}
+}
+
+func TestIngestFilesError(t *testing.T) {
+	paths := testLogs(t)
+	writeLog(t, paths[1], "garbage\n")
+
+	for _, parallel := range []int{1, 3} {
+		b := NewBuilder("www.example.com")
+		b.Parallel = parallel
+		b.Start()
+		if err := b.IngestFiles(paths); err == nil || !strings.Contains(err.Error(), "access.log.1.zst") {
+			t.Errorf("Parallel %d: IngestFiles() = %v, supposed to fail on access.log.1.zst", parallel, err)
+		}
+	}
+}
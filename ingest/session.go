@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"time"
+
+	"github.com/hsluoyz/logdance/logs"
+)
+
+type session struct {
+	last    time.Time
+	pattern string
+}
+
+// Sessionizer groups the hits into sessions by client, a session ends after
+// Timeout of inactivity.
+type Sessionizer struct {
+	// Key is the variable of the records which identifies a client, like
+	// "cookie_sid" or "http_x_user_id". The client IP and user agent are
+	// used if it is empty or missing in a record.
+	Key     string
+	Timeout time.Duration
+
+	sessions  map[string]*session
+	lastSweep time.Time
+}
+
+// NewSessionizer creates a sessionizer with the client key and inactivity
+// timeout.
+func NewSessionizer(key string, timeout time.Duration) *Sessionizer {
+	s := Sessionizer{}
+	s.Key = key
+	s.Timeout = timeout
+	s.sessions = make(map[string]*session)
+	return &s
+}
+
+func (s *Sessionizer) getClientKey(rec *logs.Record) string {
+	if s.Key != "" {
+		if key, ok := rec.Extra[s.Key]; ok && key != "" && key != "-" {
+			return key
+		}
+	}
+	return rec.Client + " " + rec.UserAgent
+}
+
+func (s *Sessionizer) isExpired(last time.Time, now time.Time) bool {
+	d := now.Sub(last)
+	if d < 0 {
+		d = -d
+	}
+	return d > s.Timeout
+}
+
+// sweep removes the expired sessions once per Timeout.
+func (s *Sessionizer) sweep(now time.Time) {
+	if !s.isExpired(s.lastSweep, now) {
+		return
+	}
+
+	for key, ss := range s.sessions {
+		if s.isExpired(ss.last, now) {
+			delete(s.sessions, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Next records the hit of rec on the page of pattern, and returns the pattern
+// of the previous hit in the same session, or "" if the session is new.
+func (s *Sessionizer) Next(rec *logs.Record, pattern string) string {
+	s.sweep(rec.Time)
+
+	key := s.getClientKey(rec)
+	ss, ok := s.sessions[key]
+	if !ok {
+		ss = &session{}
+		s.sessions[key] = ss
+	}
+
+	prev := ss.pattern
+	if ok && s.isExpired(ss.last, rec.Time) {
+		prev = ""
+	}
+
+	ss.last = rec.Time
+	ss.pattern = pattern
+	return prev
+}
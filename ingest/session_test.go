@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsluoyz/logdance/logs"
+)
+
+const testSessionLog = `1.1.1.1 - - [10/Oct/2000:13:00:00 +0000] "GET / HTTP/1.1" 200 100 "-" "UA"
+2.2.2.2 - - [10/Oct/2000:13:00:01 +0000] "GET /b HTTP/1.1" 200 100 "-" "UA"
+1.1.1.1 - - [10/Oct/2000:13:00:02 +0000] "GET /a HTTP/1.1" 200 100 "-" "UA"
+1.1.1.1 - - [10/Oct/2000:13:00:03 +0000] "GET /b HTTP/1.1" 200 100 "-" "UA"
+2.2.2.2 - - [10/Oct/2000:13:00:04 +0000] "GET /a HTTP/1.1" 200 100 "-" "UA"
+1.1.1.1 - - [10/Oct/2000:14:00:00 +0000] "GET /c HTTP/1.1" 200 100 "-" "UA"
+1.1.1.1 - - [10/Oct/2000:14:00:01 +0000] "GET /a HTTP/1.1" 200 100 "https://www.google.com/" "UA"
+`
+
+func TestSessions(t *testing.T) {
+	b := NewBuilder("www.example.com")
+	b.Sessions = NewSessionizer("", 30*time.Minute)
+	b.Start()
+	if err := b.Ingest(strings.NewReader(testSessionLog)); err != nil {
+		t.Fatal(err)
+	}
+
+	g := b.Graph
+	home, a, bb, c := g.GetPageByName("/"), g.GetPageByName("/a/"), g.GetPageByName("/b/"), g.GetPageByName("/c/")
+	if home.Links[a.Id] != 1 || len(home.Links) != 1 {
+		t.Errorf("/ links = %v, supposed to be /a/ once", home.Links)
+	}
+	if a.Links[bb.Id] != 1 || len(a.Links) != 1 {
+		t.Errorf("/a/ links = %v, supposed to be /b/ once", a.Links)
+	}
+	if bb.Links[a.Id] != 1 || len(bb.Links) != 1 {
+		t.Errorf("/b/ links = %v, supposed to be /a/ once", bb.Links)
+	}
+	if len(c.Links) != 0 {
+		t.Errorf("/c/ links = %v, supposed to be none", c.Links)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	s := NewSessionizer("cookie_sid", time.Hour)
+	now := time.Now()
+
+	rec := logs.Record{Time: now, Client: "1.1.1.1", Extra: map[string]string{"cookie_sid": "abc"}}
+	s.Next(&rec, "/a/")
+	rec = logs.Record{Time: now, Client: "2.2.2.2", Extra: map[string]string{"cookie_sid": "abc"}}
+	if prev := s.Next(&rec, "/b/"); prev != "/a/" {
+		t.Errorf("Next() = %q, supposed to be /a/ in the same session", prev)
+	}
+	rec = logs.Record{Time: now, Client: "2.2.2.2"}
+	if prev := s.Next(&rec, "/c/"); prev != "" {
+		t.Errorf("Next() = %q, supposed to be a new session", prev)
+	}
+}
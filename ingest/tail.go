@@ -0,0 +1,161 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsluoyz/logdance/util"
+)
+
+// Tailer follows a growing log file like "tail -F": when the file is rotated
+// it reads the rest of the old file and then the new file from the start, and
+// when the file is truncated it reads again from the start.
+type Tailer struct {
+	Path string
+	// FromStart reads the existing lines first, otherwise only the lines
+	// written from now on are read.
+	FromStart bool
+	// Poll is the interval to check the file for new lines.
+	Poll time.Duration
+
+	f      *os.File
+	r      *bufio.Reader
+	offset int64
+	// partial is the last line read without its "\n" yet.
+	partial string
+}
+
+// NewTailer creates a tailer of the file at path.
+func NewTailer(path string) *Tailer {
+	t := Tailer{}
+	t.Path = path
+	t.Poll = time.Second
+	return &t
+}
+
+func (t *Tailer) open(fromStart bool) error {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return err
+	}
+
+	t.offset = 0
+	if !fromStart {
+		if t.offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	t.f = f
+	t.r = bufio.NewReader(f)
+	t.partial = ""
+	return nil
+}
+
+// readLines reads the complete lines available to handle.
+func (t *Tailer) readLines(handle func(line string) error) error {
+	for {
+		s, err := t.r.ReadString('\n')
+		t.offset += int64(len(s))
+		if err == io.EOF {
+			t.partial += s
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line := strings.TrimRight(t.partial+s, "\r\n")
+		t.partial = ""
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+}
+
+// check reopens the file if it is rotated or truncated.
+func (t *Tailer) check() error {
+	fi, err := os.Stat(t.Path)
+	if os.IsNotExist(err) {
+		// The file is being rotated, wait for the new one.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	openFi, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(fi, openFi) {
+		util.LogPrint("Log rotated: ", t.Path)
+		t.f.Close()
+		return t.open(true)
+	}
+	if fi.Size() < t.offset {
+		util.LogPrint("Log truncated: ", t.Path)
+		if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.offset = 0
+		t.r.Reset(t.f)
+		t.partial = ""
+	}
+	return nil
+}
+
+// Follow calls handle with each line of the file until stop is closed, idle
+// is called each time there are no more lines for now.
+func (t *Tailer) Follow(stop <-chan struct{}, handle func(line string) error, idle func() error) error {
+	if err := t.open(t.FromStart); err != nil {
+		return err
+	}
+	defer func() {
+		t.f.Close()
+	}()
+
+	ticker := time.NewTicker(t.Poll)
+	defer ticker.Stop()
+	for {
+		if err := t.readLines(handle); err != nil {
+			return err
+		}
+		if err := idle(); err != nil {
+			return err
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		// Read the rest of the old file before checking the rotation.
+		if err := t.readLines(handle); err != nil {
+			return err
+		}
+		if err := t.check(); err != nil {
+			return err
+		}
+	}
+}
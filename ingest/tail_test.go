@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path string, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitLine(t *testing.T, lines chan string, line string) {
+	t.Helper()
+	select {
+	case l := <-lines:
+		if l != line {
+			t.Errorf("line = %q, supposed to be %q", l, line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %q", line)
+	}
+}
+
+func TestTailer(t *testing.T) {
+	path := t.TempDir() + "/access.log"
+	appendFile(t, path, "old\n")
+
+	tl := NewTailer(path)
+	tl.Poll = 10 * time.Millisecond
+	lines := make(chan string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- tl.Follow(stop, func(line string) error {
+			lines <- line
+			return nil
+		}, func() error { return nil })
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "a\npart")
+	waitLine(t, lines, "a")
+	appendFile(t, path, "ial\n")
+	waitLine(t, lines, "partial")
+
+	// Rotation: the rest of the old file, then the new file.
+	appendFile(t, path, "b\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "c\n")
+	waitLine(t, lines, "b")
+	waitLine(t, lines, "c")
+
+	// Truncation.
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "d\n")
+	waitLine(t, lines, "d")
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
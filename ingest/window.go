@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+// Window is a time window [Start, End) of the records with its own page graph.
+type Window struct {
+	Start time.Time
+	End   time.Time
+	Graph *graph.Graph
+}
+
+// getWindowStart gets the start of the window of bucket which t is in, the
+// windows are aligned to the local time of t, so the day windows start at
+// the midnights of the log.
+func getWindowStart(t time.Time, bucket time.Duration) time.Time {
+	_, offset := t.Zone()
+	d := time.Duration(offset) * time.Second
+	return t.Add(d).Truncate(bucket).Add(-d)
+}
+
+// inRange tells whether t is in [b.Since, b.Until), the unset ends are open.
+func (b *Builder) inRange(t time.Time) bool {
+	if !b.Since.IsZero() && t.Before(b.Since) {
+		return false
+	}
+	if !b.Until.IsZero() && !t.Before(b.Until) {
+		return false
+	}
+	return true
+}
+
+// getWindow returns the window of t, the window is created if it is new. It
+// returns nil if there is no bucket.
+func (b *Builder) getWindow(t time.Time) *Window {
+	if b.Bucket <= 0 {
+		return nil
+	}
+
+	start := getWindowStart(t, b.Bucket)
+	if w, ok := b.windowMap[start.Unix()]; ok {
+		return w
+	}
+
+	w := &Window{}
+	w.Start = start
+	w.End = start.Add(b.Bucket)
+	w.Graph = graph.NewGraph()
+	w.Graph.AddPage("/")
+	if b.windowMap == nil {
+		b.windowMap = make(map[int64]*Window)
+	}
+	b.windowMap[start.Unix()] = w
+
+	// The records are mostly in order, so the window is mostly the last.
+	i := sort.Search(len(b.Windows), func(i int) bool {
+		return b.Windows[i].Start.After(start)
+	})
+	b.Windows = append(b.Windows, nil)
+	copy(b.Windows[i+1:], b.Windows[i:])
+	b.Windows[i] = w
+	return w
+}
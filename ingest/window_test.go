@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetWindowStart(t *testing.T) {
+	zone := time.FixedZone("", -7*3600)
+	tm := time.Date(2000, 10, 10, 13, 55, 36, 0, zone)
+
+	testGetWindowStart(t, tm, time.Hour, time.Date(2000, 10, 10, 13, 0, 0, 0, zone))
+	testGetWindowStart(t, tm, 24*time.Hour, time.Date(2000, 10, 10, 0, 0, 0, 0, zone))
+	testGetWindowStart(t, tm, 15*time.Minute, time.Date(2000, 10, 10, 13, 45, 0, 0, zone))
+}
+
+func testGetWindowStart(t *testing.T, tm time.Time, bucket time.Duration, res time.Time) {
+	t.Helper()
+
+	if start := getWindowStart(tm, bucket); !start.Equal(res) {
+		t.Errorf("getWindowStart(%v, %v) = %v, supposed to be %v", tm, bucket, start, res)
+	}
+}
+
+const testWindowLog = `1.1.1.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 100 "-" "UA"
+1.1.1.1 - - [10/Oct/2000:13:55:37 -0700] "GET /a HTTP/1.1" 200 100 "https://example.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:15:10:00 -0700] "GET /b HTTP/1.1" 200 100 "https://example.com/a" "UA"
+1.1.1.1 - - [10/Oct/2000:14:20:00 -0700] "GET /a HTTP/1.1" 200 100 "https://example.com/" "UA"
+1.1.1.1 - - [10/Oct/2000:16:00:00 -0700] "GET /c HTTP/1.1" 200 100 "https://example.com/b" "UA"
+1.1.1.1 - - [10/Oct/2000:12:59:59 -0700] "GET /d HTTP/1.1" 200 100 "https://example.com/" "UA"
+`
+
+func TestWindows(t *testing.T) {
+	zone := time.FixedZone("", -7*3600)
+
+	b := NewBuilder("example.com")
+	b.Since = time.Date(2000, 10, 10, 13, 0, 0, 0, zone)
+	b.Until = time.Date(2000, 10, 10, 16, 0, 0, 0, zone)
+	b.Bucket = time.Hour
+	b.Start()
+	if err := b.Ingest(strings.NewReader(testWindowLog)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.Graph.Pages()) != 3 || b.Graph.HasPage("/c/") || b.Graph.HasPage("/d/") {
+		t.Errorf("Pages() = %v, supposed to be /, /a/ and /b/", b.Graph.Pages())
+	}
+
+	hours := []int{13, 14, 15}
+	if len(b.Windows) != len(hours) {
+		t.Fatalf("%d windows, supposed to be %d", len(b.Windows), len(hours))
+	}
+	for i, w := range b.Windows {
+		if w.Start.Hour() != hours[i] || w.End.Sub(w.Start) != time.Hour {
+			t.Errorf("window #%d = [%v, %v), supposed to start at %d:00", i, w.Start, w.End, hours[i])
+		}
+	}
+
+	home := b.Windows[1].Graph.GetPageByName("/")
+	a := b.Windows[1].Graph.GetPageByName("/a/")
+	if home == nil || a == nil || home.Links[a.Id] != 1 || b.Windows[1].Graph.HasPage("/b/") {
+		t.Errorf("window #1 pages = %v, supposed to be / -> /a/", b.Windows[1].Graph.Pages())
+	}
+}
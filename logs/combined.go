@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Apache/Nginx combined format, the referer and user agent are optional
+// so the common format is parsed too:
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+var combinedRe = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\S+)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// TimeLocalLayout is the layout of the Apache "%t" and the Nginx
+// "$time_local".
+const TimeLocalLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ParseCombined parses a line in the Apache/Nginx combined or common format.
+func ParseCombined(line string) (*Record, error) {
+	m := combinedRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("not in the combined format: %q", line)
+	}
+
+	rec := Record{}
+	rec.Client = m[1]
+
+	t, err := time.Parse(TimeLocalLayout, m[2])
+	if err != nil {
+		return nil, err
+	}
+	rec.Time = t
+
+	if err := rec.setRequest(unescape(m[3])); err != nil {
+		return nil, err
+	}
+
+	rec.Status, _ = strconv.Atoi(m[4])
+	rec.Bytes = parseBytes(m[5])
+	rec.Referer = parseDash(unescape(m[6]))
+	rec.UserAgent = parseDash(unescape(m[7]))
+	return &rec, nil
+}
+
+// setRequest sets the method and path from the request line like
+// "GET /index.html HTTP/1.1".
+func (rec *Record) setRequest(request string) error {
+	fields := strings.Fields(request)
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed request: %q", request)
+	}
+
+	rec.Method = fields[0]
+	rec.Path = fields[1]
+	return nil
+}
+
+// "\"" -> "\"", "\\x22" -> "\"" in the escaped strings of the logs.
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	if res, err := strconv.Unquote("\"" + s + "\""); err == nil {
+		return res
+	}
+	return s
+}
+
+func parseDash(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func parseBytes(s string) int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCombined(t *testing.T) {
+	rec, err := ParseCombined(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 \"quoted\""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	if rec.Client != "127.0.0.1" || !rec.Time.Equal(tm) || rec.Method != "GET" || rec.Path != "/apache_pb.gif?a=1" ||
+		rec.Status != 200 || rec.Bytes != 2326 || rec.Referer != "http://www.example.com/start.html" || rec.UserAgent != `Mozilla/4.08 "quoted"` {
+		t.Errorf("ParseCombined() = %+v", rec)
+	}
+
+	rec, err = ParseCombined(`::1 - - [10/Oct/2000:13:55:36 +0000] "GET / HTTP/1.1" 304 - "-" "-"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Bytes != 0 || rec.Referer != "" || rec.UserAgent != "" {
+		t.Errorf("ParseCombined() = %+v", rec)
+	}
+
+	rec, err = ParseCombined(`::1 - - [10/Oct/2000:13:55:36 +0000] "GET /common HTTP/1.1" 200 12`)
+	if err != nil || rec.Path != "/common" {
+		t.Errorf("ParseCombined() = %+v, %v", rec, err)
+	}
+
+	if _, err = ParseCombined(`::1 - - [10/Oct/2000:13:55:36 +0000] "-" 400 0 "-" "-"`); err == nil {
+		t.Error("ParseCombined() should fail on a malformed request")
+	}
+	if _, err = ParseCombined(`not a log line`); err == nil {
+		t.Error("ParseCombined() should fail on a line not in the combined format")
+	}
+}
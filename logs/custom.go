@@ -0,0 +1,167 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// token is a literal or a variable of a format string.
+type token struct {
+	literal  string
+	variable string
+}
+
+// customParser parses the lines of an Nginx-like format string. Each variable
+// ends at the literal after it, so no regex is needed.
+type customParser struct {
+	tokens []token
+}
+
+func isVariableChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// CompileFormat compiles an Nginx log_format string like
+// `$remote_addr - $remote_user [$time_local] "$request" $status` into a
+// parser. The variables known by Record like "request" and "status" set
+// its fields, the others go to Extra.
+func CompileFormat(format string) (Parser, error) {
+	p := customParser{}
+	literal := ""
+	for i := 0; i < len(format); {
+		if format[i] != '$' {
+			literal += format[i : i+1]
+			i++
+			continue
+		}
+
+		name := ""
+		if strings.HasPrefix(format[i:], "${") {
+			j := strings.Index(format[i:], "}")
+			if j == -1 {
+				return nil, fmt.Errorf("unclosed \"${\" at %d", i)
+			}
+			name = format[i+2 : i+j]
+			i += j + 1
+		} else {
+			j := i + 1
+			for j < len(format) && isVariableChar(format[j]) {
+				j++
+			}
+			name = format[i+1 : j]
+			i = j
+		}
+		if name == "" {
+			return nil, fmt.Errorf("empty variable name at %d", i)
+		}
+
+		if literal != "" {
+			p.tokens = append(p.tokens, token{literal: literal})
+			literal = ""
+		} else if len(p.tokens) != 0 {
+			return nil, fmt.Errorf("variables $%s and $%s are not separated", p.tokens[len(p.tokens)-1].variable, name)
+		}
+		p.tokens = append(p.tokens, token{variable: name})
+	}
+	if literal != "" {
+		p.tokens = append(p.tokens, token{literal: literal})
+	}
+
+	return &p, nil
+}
+
+func (p *customParser) Parse(line string) (*Record, error) {
+	m := map[string]interface{}{}
+	pos := 0
+	for i, t := range p.tokens {
+		if t.variable == "" {
+			if !strings.HasPrefix(line[pos:], t.literal) {
+				return nil, fmt.Errorf("%q expected at %d: %q", t.literal, pos, line)
+			}
+			pos += len(t.literal)
+			continue
+		}
+
+		end := len(line)
+		if i+1 < len(p.tokens) {
+			j := strings.Index(line[pos:], p.tokens[i+1].literal)
+			if j == -1 {
+				return nil, fmt.Errorf("%q expected after $%s: %q", p.tokens[i+1].literal, t.variable, line)
+			}
+			end = pos + j
+		}
+		m[t.variable] = unescape(line[pos:end])
+		pos = end
+	}
+
+	rec := Record{}
+	if err := rec.setVariables(m); err != nil {
+		return nil, fmt.Errorf("%v: %q", err, line)
+	}
+	return &rec, nil
+}
+
+// RegisterCustomFormat compiles the Nginx-like format string and registers it
+// as the format name.
+func RegisterCustomFormat(name string, format string) error {
+	p, err := CompileFormat(format)
+	if err != nil {
+		return fmt.Errorf("format %s: %v", name, err)
+	}
+
+	// The parser is stateless, so all the logs share it.
+	RegisterFormat(name, func() Parser {
+		return p
+	})
+	return nil
+}
+
+// LoadFormats registers the custom formats from the JSON file at path like:
+//
+//	{
+//	  "formats": [
+//	    {"name": "proxy", "format": "$remote_addr [$time_local] \"$request\" $status $request_time $http_x_user_id"}
+//	  ]
+//	}
+func LoadFormats(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config := struct {
+		Formats []struct {
+			Name   string `json:"name"`
+			Format string `json:"format"`
+		} `json:"formats"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for i, f := range config.Formats {
+		if f.Name == "" {
+			return fmt.Errorf("%s: format #%d: no name", path, i+1)
+		}
+		if err := RegisterCustomFormat(f.Name, f.Format); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
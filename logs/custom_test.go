@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileFormat(t *testing.T) {
+	p, err := CompileFormat(`$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" ${request_time}s uid=$http_x_user_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := p.Parse(`1.2.3.4 - - [02/Jan/2019:03:04:05 +0000] "GET /a?b=1 HTTP/1.1" 200 512 "https://example.com/" "Mozilla/5.0 \x22x\x22" 0.250s uid=42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !rec.Time.Equal(tm) || rec.Client != "1.2.3.4" || rec.Method != "GET" || rec.Path != "/a?b=1" || rec.Status != 200 ||
+		rec.Bytes != 512 || rec.Referer != "https://example.com/" || rec.UserAgent != `Mozilla/5.0 "x"` || rec.Latency != 250*time.Millisecond {
+		t.Errorf("Parse() = %+v", rec)
+	}
+	if rec.Extra["http_x_user_id"] != "42" || rec.Extra["remote_user"] != "" {
+		t.Errorf("Extra = %v", rec.Extra)
+	}
+
+	if _, err := p.Parse(`1.2.3.4 - - [02/Jan/2019:03:04:05 +0000] "GET / HTTP/1.1"`); err == nil {
+		t.Error("Parse() should fail on a line not in the format")
+	}
+}
+
+func TestCompileFormatError(t *testing.T) {
+	if _, err := CompileFormat(`$remote_addr$status`); err == nil {
+		t.Error("CompileFormat() should fail on the variables not separated")
+	}
+	if _, err := CompileFormat(`${remote_addr`); err == nil {
+		t.Error("CompileFormat() should fail on an unclosed variable")
+	}
+}
+
+func TestRegisterCustomFormat(t *testing.T) {
+	if err := RegisterCustomFormat("test-proxy", `$remote_addr|$time_iso8601|$request_method|$request_uri|$status`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		unregisterFormat("test-proxy")
+	})
+
+	f, err := DetectFormat([]string{`1.2.3.4|2019-01-02T03:04:05Z|GET|/a|200`})
+	if err != nil || f.Name != "test-proxy" {
+		t.Errorf("DetectFormat() = %v, %v, supposed to be test-proxy", f, err)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// splitQuoted splits line by spaces, a quoted value like "GET / HTTP/1.1" is
+// one field without the quotes.
+func splitQuoted(line string) []string {
+	fields := []string{}
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			return fields
+		}
+
+		if line[0] == '"' {
+			i := 1
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i > len(line) {
+				i = len(line)
+			}
+			fields = append(fields, unescape(line[1:i]))
+			if i < len(line) {
+				i++
+			}
+			line = line[i:]
+		} else {
+			i := strings.Index(line, " ")
+			if i == -1 {
+				i = len(line)
+			}
+			fields = append(fields, line[:i])
+			line = line[i:]
+		}
+	}
+}
+
+// "10.0.0.1:5678" -> "10.0.0.1"
+func parseClient(s string) string {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return s
+}
+
+// setElbRequest sets the record from the ELB request like
+// "GET https://www.example.com:443/index.html?a=1 HTTP/1.1".
+func (rec *Record) setElbRequest(request string) error {
+	if err := rec.setRequest(request); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rec.Path)
+	if err != nil {
+		return err
+	}
+	rec.Path = u.EscapedPath()
+	if rec.Path == "" {
+		rec.Path = "/"
+	}
+	if u.RawQuery != "" {
+		rec.Path += "?" + u.RawQuery
+	}
+	return nil
+}
+
+// parseElbFields parses the ELB fields from the time on:
+// time elb client:port target:port request_processing_time target_processing_time response_processing_time elb_status_code target_status_code received_bytes sent_bytes "request" "user_agent"
+func parseElbFields(fields []string, line string) (*Record, error) {
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("%d fields, at least 13 expected: %q", len(fields), line)
+	}
+
+	rec := Record{}
+	t, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return nil, err
+	}
+	rec.Time = t
+	rec.Client = parseClient(fields[2])
+
+	// The processing times are -1 if the request failed.
+	for _, s := range fields[4:7] {
+		rec.Latency += parseSeconds(s)
+	}
+
+	status, err := strconv.Atoi(fields[7])
+	if err != nil {
+		return nil, fmt.Errorf("invalid status %q: %q", fields[7], line)
+	}
+	rec.Status = status
+	rec.Bytes = parseBytes(fields[10])
+
+	if err := rec.setElbRequest(fields[11]); err != nil {
+		return nil, err
+	}
+	rec.UserAgent = parseDash(fields[12])
+
+	rec.Extra = map[string]string{"elb": fields[1], "target": fields[3]}
+	return &rec, nil
+}
+
+// ParseAlb parses a line of an AWS Application Load Balancer log, which is the
+// ELB fields after the request type like "http" or "h2".
+func ParseAlb(line string) (*Record, error) {
+	fields := splitQuoted(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+
+	switch fields[0] {
+	case "http", "https", "h2", "grpcs", "ws", "wss":
+		return parseElbFields(fields[1:], line)
+	default:
+		return nil, fmt.Errorf("unknown ALB request type %q: %q", fields[0], line)
+	}
+}
+
+// ParseElb parses a line of an AWS Classic Load Balancer log.
+func ParseElb(line string) (*Record, error) {
+	return parseElbFields(splitQuoted(line), line)
+}
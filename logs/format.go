@@ -0,0 +1,133 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Parser parses the lines of a log one by one. It returns a nil record and a
+// nil error for the lines without a request, like the comments and headers.
+// A parser may keep state between the lines, like the fields of a W3C log.
+type Parser interface {
+	Parse(line string) (*Record, error)
+}
+
+// ParserFunc is a stateless parser.
+type ParserFunc func(line string) (*Record, error)
+
+func (f ParserFunc) Parse(line string) (*Record, error) {
+	return f(line)
+}
+
+// Format is a log format, it creates a new parser for each log.
+type Format struct {
+	Name      string
+	NewParser func() Parser
+}
+
+var formatList []*Format
+var formatMap map[string]*Format
+
+func init() {
+	formatMap = make(map[string]*Format)
+
+	RegisterFormat("combined", func() Parser { return ParserFunc(ParseCombined) })
+	RegisterFormat("nginx-json", func() Parser { return ParserFunc(ParseNginxJson) })
+	RegisterFormat("w3c", func() Parser { return newW3cParser() })
+	RegisterFormat("alb", func() Parser { return ParserFunc(ParseAlb) })
+	RegisterFormat("elb", func() Parser { return ParserFunc(ParseElb) })
+}
+
+// RegisterFormat registers the format name, it replaces the format of the
+// same name. The formats are tried in their registration order when
+// detected.
+func RegisterFormat(name string, newParser func() Parser) {
+	f := Format{}
+	f.Name = name
+	f.NewParser = newParser
+
+	if _, ok := formatMap[name]; ok {
+		for i, old := range formatList {
+			if old.Name == name {
+				formatList[i] = &f
+			}
+		}
+	} else {
+		formatList = append(formatList, &f)
+	}
+	formatMap[name] = &f
+}
+
+// unregisterFormat removes the format name registered by RegisterFormat().
+func unregisterFormat(name string) {
+	if _, ok := formatMap[name]; !ok {
+		return
+	}
+
+	for i, f := range formatList {
+		if f.Name == name {
+			formatList = append(formatList[:i], formatList[i+1:]...)
+			break
+		}
+	}
+	delete(formatMap, name)
+}
+
+// GetFormat returns the format of name.
+func GetFormat(name string) (*Format, error) {
+	f, ok := formatMap[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q, supported formats: %v", name, GetFormatNames())
+	}
+	return f, nil
+}
+
+// GetFormatNames returns the names of the registered formats, sorted.
+func GetFormatNames() []string {
+	names := make([]string, 0, len(formatList))
+	for _, f := range formatList {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectFormat returns the format which parses the most of lines, which are
+// the first lines of a log.
+func DetectFormat(lines []string) (*Format, error) {
+	var best *Format
+	bestCount := 0
+	for _, f := range formatList {
+		p := f.NewParser()
+		count := 0
+		for _, line := range lines {
+			if rec, err := p.Parse(line); err == nil && rec != nil {
+				count++
+			}
+		}
+
+		if count > bestCount {
+			best = f
+			bestCount = count
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("unknown log format, supported formats: %v", GetFormatNames())
+	}
+	return best, nil
+}
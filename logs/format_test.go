@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLogs = map[string]string{
+	"combined":   `1.2.3.4 - - [02/Jan/2019:03:04:05 +0000] "GET /a?b=1 HTTP/1.1" 200 512 "https://example.com/" "Mozilla/5.0"`,
+	"nginx-json": `{"time_iso8601": "2019-01-02T03:04:05+00:00", "remote_addr": "1.2.3.4", "request": "GET /a?b=1 HTTP/1.1", "status": "200", "body_bytes_sent": "512", "http_referer": "https://example.com/", "http_user_agent": "Mozilla/5.0", "request_time": "0.250", "cookie_sid": "abc"}`,
+	"w3c": "#Software: Microsoft Internet Information Services 10.0\n" +
+		"#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port c-ip cs(User-Agent) cs(Referer) cs(Cookie) sc-status sc-bytes time-taken\n" +
+		"2019-01-02 03:04:05 10.0.0.1 GET /a b=1 443 1.2.3.4 Mozilla/5.0 https://example.com/ sid=abc;+x=y 200 512 250",
+	"alb": `https 2019-01-02T03:04:05.000000Z app/my-lb/50dc6c495c0c9188 1.2.3.4:2817 10.0.0.1:80 0.100 0.100 0.050 200 200 34 512 "GET https://www.example.com:443/a?b=1 HTTP/1.1" "Mozilla/5.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2019-01-02T03:04:05.000000Z "forward" "-" "-"`,
+	"elb": `2019-01-02T03:04:05.000000Z my-loadbalancer 1.2.3.4:2817 10.0.0.1:80 0.100 0.100 0.050 200 200 0 512 "GET http://www.example.com:80/a?b=1 HTTP/1.1" "Mozilla/5.0" - -`,
+}
+
+func TestFormats(t *testing.T) {
+	tm := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, log := range testLogs {
+		lines := strings.Split(log, "\n")
+		f, err := DetectFormat(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name != name {
+			t.Errorf("DetectFormat(%s) = %s", name, f.Name)
+		}
+
+		p := f.NewParser()
+		var rec *Record
+		for _, line := range lines {
+			if rec, err = p.Parse(line); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		if !rec.Time.Equal(tm) || rec.Client != "1.2.3.4" || rec.Method != "GET" || rec.Path != "/a?b=1" ||
+			rec.Status != 200 || rec.Bytes != 512 || rec.UserAgent != "Mozilla/5.0" {
+			t.Errorf("%s: Parse() = %+v", name, rec)
+		}
+		if name != "alb" && name != "elb" && rec.Referer != "https://example.com/" {
+			t.Errorf("%s: Referer = %q", name, rec.Referer)
+		}
+		if name != "combined" && rec.Latency != 250*time.Millisecond {
+			t.Errorf("%s: Latency = %v", name, rec.Latency)
+		}
+		if (name == "nginx-json" || name == "w3c") && rec.Extra["cookie_sid"] != "abc" {
+			t.Errorf("%s: Extra = %v", name, rec.Extra)
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := DetectFormat([]string{"not a log line"}); err == nil {
+		t.Error("DetectFormat() should fail on an unknown format")
+	}
+	if _, err := GetFormat("nope"); err == nil {
+		t.Error("GetFormat() should fail on an unknown format")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getJsonString returns the value of the first key found in m as a string.
+func getJsonString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		v, ok := m[key]
+		if !ok || v == nil {
+			continue
+		}
+
+		delete(m, key)
+		switch v := v.(type) {
+		case string:
+			return parseDash(v)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func parseSeconds(s string) time.Duration {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
+
+// setVariables sets the record from the Nginx variables in m like
+// "remote_addr" and "request", the rest of the variables go to Extra.
+func (rec *Record) setVariables(m map[string]interface{}) error {
+	if s := getJsonString(m, "time_iso8601", "time"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		rec.Time = t
+	} else if s := getJsonString(m, "time_local"); s != "" {
+		t, err := time.Parse(TimeLocalLayout, s)
+		if err != nil {
+			return err
+		}
+		rec.Time = t
+	} else if s := getJsonString(m, "msec"); s != "" {
+		rec.Time = time.Unix(0, int64(parseSeconds(s)))
+	}
+
+	rec.Client = getJsonString(m, "remote_addr", "client", "client_ip")
+
+	if request := getJsonString(m, "request"); request != "" {
+		if err := rec.setRequest(request); err != nil {
+			return err
+		}
+	} else {
+		rec.Method = getJsonString(m, "request_method", "method")
+		rec.Path = getJsonString(m, "request_uri", "uri", "path")
+		if args := getJsonString(m, "args", "query_string"); args != "" && !strings.Contains(rec.Path, "?") {
+			rec.Path += "?" + args
+		}
+	}
+	if rec.Path == "" {
+		return fmt.Errorf("no request")
+	}
+
+	rec.Status, _ = strconv.Atoi(getJsonString(m, "status"))
+	rec.Referer = getJsonString(m, "http_referer", "referer")
+	rec.UserAgent = getJsonString(m, "http_user_agent", "user_agent")
+	rec.Bytes = parseBytes(getJsonString(m, "body_bytes_sent", "bytes_sent"))
+	rec.Latency = parseSeconds(getJsonString(m, "request_time"))
+
+	if len(m) != 0 {
+		rec.Extra = make(map[string]string, len(m))
+		for key := range m {
+			rec.Extra[key] = getJsonString(m, key)
+		}
+	}
+	return nil
+}
+
+// ParseNginxJson parses a line logged by an Nginx log_format with
+// escape=json, which is a JSON object of the variables like:
+// {"time_iso8601": "2019-01-02T03:04:05+00:00", "remote_addr": "127.0.0.1", "request": "GET / HTTP/1.1", "status": "200"}
+func ParseNginxJson(line string) (*Record, error) {
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return nil, fmt.Errorf("not a JSON object: %q", line)
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return nil, err
+	}
+
+	rec := Record{}
+	if err := rec.setVariables(m); err != nil {
+		return nil, fmt.Errorf("%v: %q", err, line)
+	}
+	return &rec, nil
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import "time"
+
+// Record is a request parsed from a line of an access log.
+type Record struct {
+	Time   time.Time
+	Client string
+	Method string
+	// Path is the request URI with the query, like "/search?q=go".
+	Path      string
+	Status    int
+	Referer   string
+	UserAgent string
+	Bytes     int64
+	Latency   time.Duration
+	// Extra are the other variables of the line by their Nginx names, like
+	// "cookie_sid" or "http_x_user_id".
+	Extra map[string]string
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// w3cParser parses the W3C extended format of IIS, the fields are declared by
+// the "#Fields:" header like:
+// #Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken
+// 2019-01-02 03:04:05 10.0.0.1 GET /default.htm - 80 - 10.0.0.2 Mozilla/5.0+(Windows) - 200 0 0 15
+type w3cParser struct {
+	fields []string
+}
+
+func newW3cParser() *w3cParser {
+	return &w3cParser{}
+}
+
+// "Mozilla/5.0+(Windows)" -> "Mozilla/5.0 (Windows)"
+func parseW3cString(s string) string {
+	return strings.Replace(parseDash(s), "+", " ", -1)
+}
+
+// "a=1;+b=2" -> {"cookie_a": "1", "cookie_b": "2"}
+func parseW3cCookies(s string, extra map[string]string) {
+	for _, cookie := range strings.Split(s, ";") {
+		cookie = strings.TrimLeft(cookie, "+ ")
+		if i := strings.Index(cookie, "="); i != -1 {
+			extra["cookie_"+cookie[:i]] = cookie[i+1:]
+		}
+	}
+}
+
+func (p *w3cParser) Parse(line string) (*Record, error) {
+	if strings.HasPrefix(line, "#") {
+		if strings.HasPrefix(line, "#Fields:") {
+			p.fields = strings.Fields(line[len("#Fields:"):])
+		}
+		return nil, nil
+	}
+	if p.fields == nil {
+		return nil, fmt.Errorf("no #Fields header before: %q", line)
+	}
+
+	values := strings.Fields(line)
+	if len(values) != len(p.fields) {
+		return nil, fmt.Errorf("%d values for %d fields: %q", len(values), len(p.fields), line)
+	}
+
+	rec := Record{}
+	date, tm, query := "", "", ""
+	for i, field := range p.fields {
+		value := values[i]
+		switch field {
+		case "date":
+			date = value
+		case "time":
+			tm = value
+		case "c-ip":
+			rec.Client = parseDash(value)
+		case "cs-method":
+			rec.Method = parseDash(value)
+		case "cs-uri-stem":
+			rec.Path = parseDash(value)
+		case "cs-uri-query":
+			query = parseDash(value)
+		case "sc-status":
+			rec.Status, _ = strconv.Atoi(value)
+		case "cs(Referer)":
+			rec.Referer = parseDash(value)
+		case "cs(User-Agent)":
+			rec.UserAgent = parseW3cString(value)
+		case "sc-bytes":
+			rec.Bytes = parseBytes(value)
+		case "time-taken":
+			ms, _ := strconv.Atoi(value)
+			rec.Latency = time.Duration(ms) * time.Millisecond
+		default:
+			if rec.Extra == nil {
+				rec.Extra = make(map[string]string)
+			}
+			if field == "cs(Cookie)" {
+				parseW3cCookies(parseDash(value), rec.Extra)
+			}
+			rec.Extra[field] = parseW3cString(value)
+		}
+	}
+
+	if rec.Path == "" {
+		return nil, fmt.Errorf("no cs-uri-stem: %q", line)
+	}
+	if query != "" {
+		rec.Path += "?" + query
+	}
+
+	// The W3C times are in UTC.
+	t, err := time.Parse("2006-01-02 15:04:05", date+" "+tm)
+	if err != nil {
+		return nil, err
+	}
+	rec.Time = t
+	return &rec, nil
+}
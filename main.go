@@ -16,132 +16,126 @@ package main
 
 import (
 	"fmt"
-	"strings"
+	"os"
+	"os/signal"
+	"time"
 
-	"github.com/gocolly/colly"
+	"github.com/hsluoyz/logdance/crawler"
 	"github.com/hsluoyz/logdance/graph"
 	"github.com/hsluoyz/logdance/pattern"
-	"github.com/hsluoyz/logdance/render"
-	"github.com/hsluoyz/logdance/target"
+	"github.com/hsluoyz/logdance/server"
 )
 
-func printPage(name string, depth int, id uint32, idx int) {
-	fmt.Printf("%s[%d-%d] %s\n", strings.Repeat("  ", depth), id, idx, name)
+// verbosity controls the console output: 0 prints nothing, 1 prints the new
+// pages and 2 also prints the redirections and links.
+var verbosity = 1
+
+// crawlOptions are the options of crawl().
+type crawlOptions struct {
+	// maxDepth is the maximum crawl depth, 0 means unlimited.
+	maxDepth     int
+	parallel     int
+	hostParallel map[string]int
+	normalizer   *pattern.Normalizer
+	// server serves the graph while crawling if it is not nil.
+	server *server.Server
+	// statePath is the file to save the crawl state every stateInterval and
+	// when interrupted, resume resumes the crawl saved there.
+	statePath     string
+	stateInterval time.Duration
+	resume        bool
 }
 
-func crawl(targetBase string) {
-	fullDomain := pattern.GetFullDomainName(targetBase)
-	domain := pattern.GetDomainName(targetBase)
-	pattern.GenerateCustomRe(fullDomain)
-
-	graph.AddPage("/")
-	printPage("/", 0, 0, 0)
-	c := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.80 Safari/537.36"),
-		)
-
-	// Find and visit all links
-	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		//fmt.Printf("a[href]: %s\n", e.Attr("href"))
-		//fmt.Printf("path: %s\n", e.Request.URL.Path)
-
-		r := e.Request
-		href := e.Attr("href")
-		after := r.URL.Path
-		if r.URL.RawQuery != "" {
-			after = strings.TrimRight(r.URL.Path, "/") + "?" + r.URL.RawQuery
-		}
-		if !strings.HasSuffix(after, "/") {
-			after += "/"
-		}
-
-		// Get index of "a[href]".
-		idx := e.Index
-
-		// Check redirection.
-		before := r.Ctx.Get("path")
-		if before == "" {
-			before = "/"
-		}
-		if idx == 0 && before != after {
-			fmt.Printf("(%s != %s)\n", before, after)
-			graph.AddRedirectPage(pattern.GetPattern(before), pattern.GetPattern(after))
-		}
-
-		// Get source from previous target.
-		source := r.Ctx.Get(fmt.Sprintf("pattern-%d", r.Depth - 1))
-		if source == "" {
-			source = "/"
-		}
-
-		// For breakpoint based on ID and index.
-		//if r.ID == 8 && idx == 8 {
-		//	println("breakpoint here.")
-		//}
-
-		target := pattern.StripDomainName(href, domain)
-
-		// Targets like "http://xxx.com", "mailto:xxx@xxx.com", "#tag" will be ignored.
-		if strings.HasPrefix(target, "http") || strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "#") {
-			return
-		}
-
-		// Targets like "images/test.jpg/" will be ignored.
-		//if !pattern.IsHtml(target) {
-		//	return
-		//}
-
-		// Convert relative URL to site-absolute URL.
-		// e.g., "./directions/index.html/" -> "/survivor/directions/index.html/"
-		target = pattern.GetAbsolutePath(r.URL.Path, target)
-
-		// Enforce to add the trailing "/" for each path.
-		if !strings.HasSuffix(target, "/") {
-			target += "/"
+// crawl visits targetBase and the pages linked from it, and returns the page
+// graph.
+func crawl(targetBase string, opts crawlOptions) (*graph.Graph, error) {
+	c := crawler.NewCrawler()
+	c.Normalizer = opts.normalizer
+	c.MaxDepth = opts.maxDepth
+	c.Parallel = opts.parallel
+	c.HostParallel = opts.hostParallel
+	c.Verbosity = verbosity
+	if opts.resume {
+		if err := c.LoadState(opts.statePath); err != nil {
+			return nil, err
 		}
+	}
+	if opts.server != nil {
+		opts.server.SetGraph(c.Graph)
+	}
 
-		status := "ok"
-		sPattern := pattern.GetPattern(source)
-		tPattern := pattern.GetPattern(target)
-		if sPattern == tPattern {
-			return
-		}
-		// Do not handle the main page again by recognizing "index.htm".
-		if strings.HasPrefix(tPattern, "/index.htm") {
-			return
-		}
+	if opts.statePath == "" {
+		err := c.Crawl(targetBase)
+		return c.Graph, err
+	}
 
-		if graph.HasPage(tPattern) {
-			status = "already done"
-		} else {
-			printPage(tPattern, r.Depth, r.ID, idx)
-		}
+	stop := keepState(c, opts.statePath, opts.stateInterval)
+	err := c.Crawl(targetBase)
+	stop()
+	if err != nil {
+		return c.Graph, err
+	}
+	return c.Graph, c.SaveState(opts.statePath)
+}
 
-		graph.AddLink(sPattern, tPattern)
-		// fmt.Printf("New link: [%s] --> [%s]: %s\n", sPattern, tPattern, status)
+// keepState saves the state of the crawl to path every interval and when
+// interrupted by Ctrl-C, which stops the crawl. The returned function stops
+// saving.
+func keepState(c *crawler.Crawler, path string, interval time.Duration) func() {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	var ticker *time.Ticker
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		ticks = ticker.C
+	}
 
-		if status == "ok" {
-			r.Ctx.Put("path", target)
-			r.Ctx.Put(fmt.Sprintf("pattern-%d", r.Depth), tPattern)
-			r.Visit(href)
+	go func() {
+		for {
+			select {
+			case <-ticks:
+				if err := c.SaveState(path); err != nil {
+					fmt.Fprintf(os.Stderr, "logdance crawl: %v\n", err)
+				}
+			case <-signals:
+				if err := c.SaveState(path); err != nil {
+					fmt.Fprintf(os.Stderr, "logdance crawl: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Crawl interrupted, the state is saved to %s, run again with -resume to continue\n", path)
+				os.Exit(130)
+			case <-done:
+				signal.Stop(signals)
+				if ticker != nil {
+					ticker.Stop()
+				}
+				return
+			}
 		}
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-		//fmt.Printf("OnRequest: %s\n", r.URL.Path)
-	})
-
-	c.OnResponse(func(r *colly.Response) {
-		//fmt.Printf("OnResponse: %s\n", r.Request.URL.Path)
-	})
-
-	err := c.Visit(targetBase)
-	if err != nil {
-		panic(err)
+	}()
+	return func() {
+		close(done)
 	}
 }
 
 func main() {
-	crawl(target.Url)
-	render.GenerateJson()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "logdance: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "logdance %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,130 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Classifier recognises a type of path segment by its regex, and the segment
+// is replaced with the typed placeholder like "{uuid}".
+type Classifier struct {
+	Name  string
+	Regex string
+
+	re *regexp.Regexp
+	// order is the precedence of the classifier, the order it is first
+	// registered in.
+	order int
+	// check tells whether a segment matching re is of the type, it is
+	// optional.
+	check func(segment string) bool
+}
+
+var classifierMap map[string]*Classifier
+
+// mixedCaseRe matches a segment with both upper and lower case letters.
+var mixedCaseRe = regexp.MustCompile("[A-Z].*[a-z]|[a-z].*[A-Z]")
+
+func init() {
+	classifierMap = make(map[string]*Classifier)
+
+	RegisterClassifier("uuid", "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}")
+	// The built-in classifiers are tried in this order, so "20240131" is a
+	// date and not an int, "12345678" is an int and not a date or hex.
+	RegisterClassifier("date", "[0-9]{4}-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])|[0-9]{4}(0[1-9]|1[0-2])(0[1-9]|[12][0-9]|3[01])")
+	RegisterClassifier("int", "[0-9]+")
+	RegisterClassifier("hex", "[0-9a-f]{8,}|[0-9A-F]{8,}")
+	RegisterClassifier("lang", "[a-z]{2}[-_][A-Za-z]{2}|ar|de|en|es|fr|it|ja|ko|nl|pl|pt|ru|sv|tr|zh")
+	RegisterClassifier("slug", "[a-z0-9]+(-[a-z0-9]+){2,}")
+	RegisterClassifier("base64", "[A-Za-z0-9_-]{20,}={0,2}")
+	// The random bytes in base64 mix the cases, unlike the long slugs like
+	// "getting_started_with_the_api".
+	classifierMap["base64"].check = mixedCaseRe.MatchString
+}
+
+// RegisterClassifier registers the classifier name which matches the whole
+// segment with expr, it replaces the classifier of the same name and keeps its
+// precedence. The classifiers are tried in the order they are registered, the
+// built-in ones first.
+func RegisterClassifier(name string, expr string) error {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf("classifier %s: %v", name, err)
+	}
+
+	c := Classifier{}
+	c.Name = name
+	c.Regex = expr
+	c.re = re
+	c.order = len(classifierMap)
+	if old, ok := classifierMap[name]; ok {
+		c.order = old.order
+	}
+	classifierMap[name] = &c
+	return nil
+}
+
+func getClassifiers(names []string) ([]*Classifier, error) {
+	classifiers := []*Classifier{}
+	for _, name := range names {
+		c, ok := classifierMap[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown classifier %q", name)
+		}
+		classifiers = append(classifiers, c)
+	}
+	sort.Slice(classifiers, func(i, j int) bool {
+		return classifiers[i].order < classifiers[j].order
+	})
+	return classifiers, nil
+}
+
+// "/user/123/f47ac10b-58cc-4372-a567-0e02b2c3d479" -> "/user/{int}/{uuid}"
+func classify(path string, classifiers []*Classifier) string {
+	query := ""
+	if i := strings.Index(path, "?"); i != -1 {
+		path, query = path[:i], path[i:]
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		for _, c := range classifiers {
+			if c.re.MatchString(segment) && (c.check == nil || c.check(segment)) {
+				segments[i] = "{" + c.Name + "}"
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/") + query
+}
+
+// getPlaceholderRegex returns the regex of the segment which matches the typed
+// placeholder like "{int}", or "" if segment is not a placeholder.
+func getPlaceholderRegex(segment string) string {
+	if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+		return ""
+	}
+
+	c, ok := classifierMap[segment[1:len(segment)-1]]
+	if !ok {
+		return ""
+	}
+	return "(?:" + c.Regex + ")"
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import "testing"
+
+func TestClassifiers(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"sites": {"*": {"rules": [], "classifiers": ["uuid", "date", "int", "hex", "lang", "slug", "base64"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNormalizer()
+	n.SetRules(rs)
+	n.GenerateCustomRe("example.com")
+
+	testNormalizerPattern(t, n, "/user/123/", "/user/{int}/")
+	testNormalizerPattern(t, n, "/user/f47ac10b-58cc-4372-a567-0e02b2c3d479/", "/user/{uuid}/")
+	testNormalizerPattern(t, n, "/commit/9fceb02d0ae598e95dc970b74767f19372d61af8/", "/commit/{hex}/")
+	testNormalizerPattern(t, n, "/archive/2018-01-02/", "/archive/{date}/")
+	testNormalizerPattern(t, n, "/en-US/docs/", "/{lang}/docs/")
+	testNormalizerPattern(t, n, "/fr/docs/", "/{lang}/docs/")
+	testNormalizerPattern(t, n, "/blog/the-blog-title/", "/blog/{slug}/")
+	testNormalizerPattern(t, n, "/file/aGVsbG8gd29ybGQhISEhISE=/", "/file/{base64}/")
+	testNormalizerPattern(t, n, "/docs/getting_started_with_the_api/", "/docs/getting_started_with_the_api/")
+	testNormalizerPattern(t, n, "/docs/GETTING_STARTED_WITH_THE_API/", "/docs/GETTING_STARTED_WITH_THE_API/")
+	testNormalizerPattern(t, n, "/v2/page5?id=7", "/v*/page*?id=*")
+}
+
+func TestClassifiersPrecedence(t *testing.T) {
+	// The classifiers are tried in the built-in order, not the listed one.
+	rs, err := ParseRules([]byte(`{"sites": {"*": {"rules": [], "digits": false, "classifiers": ["hex", "int", "date"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNormalizer()
+	n.SetRules(rs)
+	n.GenerateCustomRe("example.com")
+
+	testNormalizerPattern(t, n, "/archive/20240131/", "/archive/{date}/")
+	testNormalizerPattern(t, n, "/order/12345678/", "/order/{int}/")
+	testNormalizerPattern(t, n, "/archive/20241331/", "/archive/{int}/")
+	testNormalizerPattern(t, n, "/commit/deadbeef/", "/commit/{hex}/")
+}
+
+func TestClassifiersInference(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"sites": {"*": {"rules": [], "classifiers": ["int"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNormalizer()
+	n.SetRules(rs)
+	n.GenerateCustomRe("example.com")
+	n.SetInferrer(NewInferrer(2))
+	var inferred *Rule
+	n.AddRuleListener(func(r *Rule) {
+		inferred = r
+	})
+
+	testNormalizerPattern(t, n, "/user/1/", "/user/{int}/")
+	testNormalizerPattern(t, n, "/user/2/", "/user/{int}/")
+	testNormalizerPattern(t, n, "/user/3/posts/hello/", "/user/{int}/posts/hello/")
+	testNormalizerPattern(t, n, "/user/4/posts/world/", "/user/{int}/posts/*/")
+
+	// The pattern made before the rule is renamed by it.
+	if inferred == nil {
+		t.Fatal("no rule listener call")
+	}
+	if res := inferred.Apply("/user/{int}/posts/hello/"); res != "/user/{int}/posts/*/" {
+		t.Errorf("Apply(/user/{int}/posts/hello/) = %s, supposed to be /user/{int}/posts/*/", res)
+	}
+}
+
+func TestUnknownClassifier(t *testing.T) {
+	testParseRulesError(t, `{"sites": {"*": {"rules": [], "classifiers": ["nope"]}}}`, `site "*": unknown classifier "nope"`)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var digitsRe = regexp.MustCompile("[0-9]+")
+
+type segmentNode struct {
+	Children map[string]*segmentNode `json:"children,omitempty"`
+	// FanOut is the number of the children which are not patterns.
+	FanOut  int  `json:"fanOut,omitempty"`
+	Learned bool `json:"learned,omitempty"`
+}
+
+func newSegmentNode() *segmentNode {
+	sn := segmentNode{}
+	sn.Children = make(map[string]*segmentNode)
+	return &sn
+}
+
+// Inferrer learns wildcard rules from the paths seen during a crawl. When the
+// number of distinct segments under a common prefix like "/author" reaches
+// MinFanOut, it proposes the rule "/author/alice" -> "/author/*".
+type Inferrer struct {
+	MinFanOut int
+
+	root  *segmentNode
+	rules []*Rule
+}
+
+// NewInferrer creates an inferrer which learns a rule when minFanOut siblings
+// are seen.
+func NewInferrer(minFanOut int) *Inferrer {
+	in := Inferrer{}
+	in.MinFanOut = minFanOut
+	in.root = newSegmentNode()
+	return &in
+}
+
+func getSegments(path string) []string {
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+
+	segments := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// isPatternSegment tells whether segment is made by a rule or a classifier,
+// like "*", "page*" or "{int}".
+func isPatternSegment(segment string) bool {
+	return strings.Contains(segment, "*") || getPlaceholderRegex(segment) != ""
+}
+
+// isPatternPath tells whether path is a pattern like "/list/page*/" rather
+// than a path of the site.
+func isPatternPath(path string) bool {
+	for _, segment := range getSegments(path) {
+		if isPatternSegment(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// The rule also matches the patterns made before it, like "/page*/author/bob"
+// and "/user/{int}/posts/abc", so their pages can be renamed.
+//
+// "/page5/author" -> "^(/page(?:[0-9]+|\*)/author)/[^/?]+(.*)$"
+// "/user/{int}/posts" -> "^(/user/(?:(?:[0-9]+)|\{int\})/posts)/[^/?]+(.*)$"
+func newPrefixRule(prefix []string) *Rule {
+	expr := ""
+	for _, segment := range prefix {
+		if re := getPlaceholderRegex(segment); re != "" {
+			expr += "/(?:" + re + "|" + regexp.QuoteMeta(segment) + ")"
+		} else {
+			expr += "/" + digitsRe.ReplaceAllString(regexp.QuoteMeta(segment), `(?:[0-9]+|\*)`)
+		}
+	}
+
+	r := Rule{}
+	r.Name = "inferred /" + strings.Join(prefix, "/") + "/*"
+	r.Regex = "^(" + expr + ")/[^/?]+(.*)$"
+	r.Replace = "$1/*$2"
+	r.compile()
+	return &r
+}
+
+// Observe adds path to the seen paths, and returns the newly learned rule or
+// nil. The root "/" never gets a wildcard. The pattern segments like "page*"
+// and "{int}" are not counted as siblings.
+func (in *Inferrer) Observe(path string) *Rule {
+	segments := getSegments(path)
+
+	node := in.root
+	for i, segment := range segments {
+		if node.Learned {
+			return nil
+		}
+
+		// The leaves have no children when loaded from JSON.
+		if node.Children == nil {
+			node.Children = make(map[string]*segmentNode)
+		}
+		child, ok := node.Children[segment]
+		if !ok {
+			child = newSegmentNode()
+			node.Children[segment] = child
+
+			if !isPatternSegment(segment) {
+				node.FanOut++
+				if i > 0 && node.FanOut >= in.MinFanOut {
+					node.Learned = true
+					r := newPrefixRule(segments[:i])
+					in.rules = append(in.rules, r)
+					return r
+				}
+			}
+		}
+		node = child
+	}
+	return nil
+}
+
+// Rules returns the learned rules in the order they were learned.
+func (in *Inferrer) Rules() []*Rule {
+	return in.rules
+}
+
+type inferrerState struct {
+	Root  *segmentNode `json:"root"`
+	Rules []*Rule      `json:"rules,omitempty"`
+}
+
+// MarshalJSON saves the seen paths and the learned rules, so the inference of
+// a resumed crawl goes on from them. MinFanOut is not saved.
+func (in *Inferrer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inferrerState{in.root, in.rules})
+}
+
+// UnmarshalJSON loads the seen paths and the learned rules saved by
+// MarshalJSON().
+func (in *Inferrer) UnmarshalJSON(data []byte) error {
+	st := inferrerState{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for _, r := range st.Rules {
+		if err := r.compile(); err != nil {
+			return err
+		}
+	}
+	if st.Root == nil {
+		st.Root = newSegmentNode()
+	}
+
+	in.root = st.Root
+	in.rules = st.Rules
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testNormalizerPattern(t *testing.T, n *Normalizer, path string, res string) {
+	t.Helper()
+	myRes := n.GetPattern(path)
+	if myRes != res {
+		t.Errorf("GetPattern(%s) = %s, supposed to be %s", path, myRes, res)
+	}
+}
+
+func TestInferrerPatterns(t *testing.T) {
+	n := NewNormalizer()
+	n.GenerateCustomRe("infer.example.com")
+	in := NewInferrer(3)
+	n.SetInferrer(in)
+	called := false
+	n.RemoveRuleListener(n.AddRuleListener(func(r *Rule) {
+		called = true
+	}))
+
+	// Normalizing a pattern again does not count it as a sibling.
+	testNormalizerPattern(t, n, "/list/page5/", "/list/page*/")
+	testNormalizerPattern(t, n, "/list/page*/", "/list/page*/")
+	testNormalizerPattern(t, n, "/list/page6/", "/list/page*/")
+	if in.Observe("/list/page*/") != nil || in.Observe("/list/{int}/") != nil {
+		t.Error("Observe() of a pattern learned a rule")
+	}
+	if len(in.Rules()) != 0 {
+		t.Fatalf("len(Rules()) = %d, supposed to be 0", len(in.Rules()))
+	}
+
+	testNormalizerPattern(t, n, "/list/page7/", "/list/*/")
+	if len(in.Rules()) != 1 {
+		t.Fatalf("len(Rules()) = %d, supposed to be 1", len(in.Rules()))
+	}
+	if called {
+		t.Error("the removed rule listener was called")
+	}
+}
+
+func TestInferrer(t *testing.T) {
+	n := NewNormalizer()
+	n.GenerateCustomRe("infer.example.com")
+	in := NewInferrer(3)
+	n.SetInferrer(in)
+	var inferred *Rule
+	n.AddRuleListener(func(r *Rule) {
+		inferred = r
+	})
+
+	testNormalizerPattern(t, n, "/about/", "/about/")
+	testNormalizerPattern(t, n, "/contact/", "/contact/")
+	testNormalizerPattern(t, n, "/author/alice/", "/author/alice/")
+	testNormalizerPattern(t, n, "/author/bob/", "/author/bob/")
+	testNormalizerPattern(t, n, "/author/carol/", "/author/*/")
+	testNormalizerPattern(t, n, "/author/dave/quotes/", "/author/*/quotes/")
+	testNormalizerPattern(t, n, "/page5/author/erin", "/page*/author/erin")
+
+	if inferred == nil {
+		t.Fatal("no rule listener call")
+	}
+	if res := inferred.Apply("/author/alice/"); res != "/author/*/" {
+		t.Errorf("Apply(/author/alice/) = %s, supposed to be /author/*/", res)
+	}
+
+	if len(in.Rules()) != 1 {
+		t.Fatalf("len(Rules()) = %d, supposed to be 1", len(in.Rules()))
+	}
+	rs := n.ExportRules("infer.example.com")
+	if err := rs.Validate(); err != nil {
+		t.Error(err)
+	}
+	if r := rs.Sites["infer.example.com"].Rules[0]; r.Name != "inferred /author/*" || r.Regex != "^(/author)/[^/?]+(.*)$" {
+		t.Errorf("ExportRules() = %+v, supposed to be the inferred /author/* rule", r)
+	}
+}
+
+func TestInferrerJSON(t *testing.T) {
+	in := NewInferrer(3)
+	for _, path := range []string{"/author/alice/", "/author/bob/", "/list/a/", "/list/b/", "/list/c/"} {
+		in.Observe(path)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in2 := NewInferrer(3)
+	if err := json.Unmarshal(data, in2); err != nil {
+		t.Fatal(err)
+	}
+	if r := in2.Observe("/list/d/"); r != nil {
+		t.Errorf("Observe(/list/d/) = %s, supposed to be learned before", r.Name)
+	}
+	if r := in2.Observe("/author/carol/"); r == nil || r.Apply("/author/carol/") != "/author/*/" {
+		t.Errorf("Observe(/author/carol/) = %+v, supposed to be the rule /author/*", r)
+	}
+	if len(in2.Rules()) != 2 || in2.Rules()[0].Apply("/list/e/") != "/list/*/" {
+		t.Errorf("Rules() = %+v, supposed to be /list/* and /author/*", in2.Rules())
+	}
+
+	if err := json.Unmarshal([]byte(`{"rules": [{"regex": "("}]}`), in2); err == nil {
+		t.Error("Unmarshal() of a bad rule succeeded, supposed to fail")
+	}
+}
@@ -15,13 +15,41 @@
 package pattern
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/hsluoyz/logdance/util"
 )
 
+// keyStore holds the built-in keys per site, used when no rules are loaded
+// for a site.
 var keyStore map[string][]string
-var customRe []*regexp.Regexp
+
+// Normalizer turns the paths of a site into patterns. Each normalizer owns its
+// rules, so several sites can be handled at the same time. A normalizer is
+// safe for concurrent use.
+type Normalizer struct {
+	mu       sync.RWMutex
+	rules    *Rules
+	site     *SiteRules
+	inferrer *Inferrer
+
+	ruleListeners    map[int]func(r *Rule)
+	nextRuleListener int
+}
+
+var defaultNormalizer = NewNormalizer()
+
+// NewNormalizer creates a normalizer with the built-in per-site keys.
+func NewNormalizer() *Normalizer {
+	n := Normalizer{}
+	n.site = &SiteRules{}
+	return &n
+}
 
 func init() {
 	keyStore = make(map[string][]string)
@@ -33,7 +61,89 @@ func init() {
 	keyStore["books.toscrape.com"] = []string{"books", "catalogue"}
 }
 
+// SetRules sets the rules which override the built-in keys, they take effect
+// from the next GenerateCustomRe().
+func (n *Normalizer) SetRules(rs *Rules) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.rules = rs
+}
+
+// SetInferrer enables the inference of wildcard rules with in, the learned
+// rules are applied from then on after the other rules.
+func (n *Normalizer) SetInferrer(in *Inferrer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.inferrer = in
+}
+
+// AddRuleListener adds f to be called with each inferred rule, so the pages
+// normalized before the rule can be renamed with Rule.Apply(). It returns the
+// id of f for RemoveRuleListener().
+func (n *Normalizer) AddRuleListener(f func(r *Rule)) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.ruleListeners == nil {
+		n.ruleListeners = make(map[int]func(r *Rule))
+	}
+	n.nextRuleListener++
+	n.ruleListeners[n.nextRuleListener] = f
+	return n.nextRuleListener
+}
+
+// RemoveRuleListener removes the listener of id returned by AddRuleListener().
+func (n *Normalizer) RemoveRuleListener(id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.ruleListeners, id)
+}
+
+// ExportRules returns the rules of fullDomain in use, including the inferred
+// ones, so they can be saved into a rules file.
+func (n *Normalizer) ExportRules(fullDomain string) *Rules {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	site := *n.site
+	rs := Rules{}
+	rs.Sites = map[string]*SiteRules{fullDomain: &site}
+	return &rs
+}
+
+// ExportInferrer returns the state of the inferrer set by SetInferrer() as
+// JSON, or nil if there is no inferrer.
+func (n *Normalizer) ExportInferrer() (json.RawMessage, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.inferrer == nil {
+		return nil, nil
+	}
+	return json.Marshal(n.inferrer)
+}
+
+// ImportInferrer loads the state exported by ExportInferrer() into the
+// inferrer, it is ignored if there is no inferrer.
+func (n *Normalizer) ImportInferrer(data json.RawMessage) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.inferrer == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, n.inferrer)
+}
+
+// GetPattern gets the pattern of path with the default normalizer.
 func GetPattern(path string) string {
+	return defaultNormalizer.GetPattern(path)
+}
+
+func (n *Normalizer) GetPattern(path string) string {
 	// "/page#tag" -> "/page"
 	// "/page/#tag" -> "/page"
 	re, _ := regexp.Compile("/?#.*")
@@ -47,17 +157,84 @@ func GetPattern(path string) string {
 	//re, _ := regexp.Compile("(products/)[^/]*(.*)")
 	//path = re.ReplaceAllString(path, "$1*$2")
 
-	for _, re := range customRe {
-		path = re.ReplaceAllString(path, "$1/*$2")
+	n.mu.RLock()
+	site := n.site
+	rules := site.Rules
+	inferrer := n.inferrer
+	n.mu.RUnlock()
+
+	// Only the paths of the site are observed, a pattern like "/list/page*/"
+	// normalized again would be counted as a sibling of "/list/page5/".
+	if isPatternPath(path) {
+		inferrer = nil
 	}
 
-	// "/query?id=123" -> "/query?id=*"
-	re, _ = regexp.Compile("=[^&=]*")
-	path = re.ReplaceAllString(path, "=*")
+	for _, r := range rules {
+		path = r.Apply(path)
+	}
+
+	// The inferrer sees the typed segments, so "/user/123" and "/user/456"
+	// are not counted as siblings when the segments are classified.
+	if inferrer != nil {
+		n.mu.Lock()
+		r := inferrer.Observe(classify(path, site.classifiers))
+		var listeners []func(r *Rule)
+		if r != nil {
+			util.LogPrint("Inferred rule: ", r.Name)
+			rules := site.Rules
+			site.Rules = append(rules[:len(rules):len(rules)], r)
+			for _, f := range n.ruleListeners {
+				listeners = append(listeners, f)
+			}
+		}
+		n.mu.Unlock()
+
+		if r != nil {
+			for _, f := range listeners {
+				f(r)
+			}
+			path = r.Apply(path)
+		}
+	}
+
+	// "/user/f47ac10b-58cc-4372-a567-0e02b2c3d479" -> "/user/{uuid}"
+	if len(site.classifiers) != 0 {
+		path = classify(path, site.classifiers)
+	}
+
+	// "/query?b=2&a=1&utm_source=x" -> "/query?a=*&b=*" with the query
+	// policy, the query is put back after the digits are replaced so the kept
+	// values stay as they are.
+	query := ""
+	if site.Query != nil {
+		if i := strings.Index(path, "?"); i != -1 {
+			rawQuery := path[i+1:]
+			path, query = path[:i], site.Query.apply(rawQuery, site.queryValues())
+			// The "/" added by the crawler goes back to the path if no key
+			// is left: "/list?utm_source=x/" -> "/list/" like "/list/".
+			if query == "" && strings.HasSuffix(rawQuery, "/") && !strings.HasSuffix(path, "/") {
+				path += "/"
+			}
+		}
+	} else if site.queryValues() {
+		// "/query?id=123" -> "/query?id=*"
+		re, _ = regexp.Compile("=[^&=]*")
+		path = re.ReplaceAllString(path, "=*")
+	}
 
-	// "/page5" -> "/page*"
-	re, _ = regexp.Compile("[0-9]+")
-	path = re.ReplaceAllString(path, "*")
+	// "/page5" -> "/page*", but "{base64}" stays as it is.
+	if site.digits() {
+		re, _ = regexp.Compile("{[A-Za-z0-9_]+}|[0-9]+")
+		path = re.ReplaceAllStringFunc(path, func(s string) string {
+			if strings.HasPrefix(s, "{") {
+				return s
+			}
+			return "*"
+		})
+	}
+	if query != "" {
+		path += "?" + query
+	}
 
 	// "/products/abc.html" -> "/products/*.html"
 	if strings.Contains(path, "*") {
@@ -68,34 +245,45 @@ func GetPattern(path string) string {
 	return path
 }
 
-func GetFullDomainName(url string) string {
+// GetFullDomainName gets the host of url, e.g., "https://www.example.com/" ->
+// "www.example.com".
+func GetFullDomainName(url string) (string, error) {
 	i := strings.Index(url, "//")
 	if i == -1 {
-		panic("GetDomainName() error: no \"//\" in url")
+		return "", fmt.Errorf("no \"//\" in url %q", url)
 	}
 	i += 2
 
 	j := len(url)
-	if url[len(url) - 1] == '/' {
-		j --
+	if url[len(url)-1] == '/' {
+		j--
+	}
+	if i >= j {
+		return "", fmt.Errorf("no host in url %q", url)
 	}
 
-	return url[i:j]
+	return url[i:j], nil
 }
 
-func GetDomainName(url string) string {
-	full := GetFullDomainName(url)
+// GetDomainName gets the registered domain of url, e.g.,
+// "https://www.example.com/" -> "example.com". A host without a dot like
+// "localhost:8080" is its own domain.
+func GetDomainName(url string) (string, error) {
+	full, err := GetFullDomainName(url)
+	if err != nil {
+		return "", err
+	}
 
 	i := strings.LastIndex(full, ".")
 	if i == -1 {
-		panic("GetDomainName() error: no \".\" in url")
+		return full, nil
 	}
 
 	j := strings.LastIndex(full[:i], ".")
 	if j == -1 {
-		return full
+		return full, nil
 	} else {
-		return full[j+1:]
+		return full[j+1:], nil
 	}
 }
 
@@ -127,37 +315,60 @@ func StripDomainName(url string, domain string) string {
 	}
 }
 
+// GenerateCustomRe generates the custom regexes of the default normalizer.
+func GenerateCustomRe(fullDomain string) {
+	defaultNormalizer.GenerateCustomRe(fullDomain)
+}
+
+// GenerateCustomRe selects the rules of fullDomain: the loaded rules of the
+// host, or else the loaded rules of "*", or else the built-in keys.
+//
 // domain is like "/author/alice"
 // regex is like "(author)/[^/]+(.*)"
 // replaced with "$1/*$2"
-func GenerateCustomRe(fullDomain string) {
-	if keys, ok := keyStore[fullDomain]; ok {
-		for _, key := range keys {
-			expr := "(" + key + ")/[^/]+(.*)"
-			re, _ := regexp.Compile(expr)
-			customRe = append(customRe, re)
+func (n *Normalizer) GenerateCustomRe(fullDomain string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.rules != nil {
+		site, ok := n.rules.Sites[fullDomain]
+		if !ok {
+			site, ok = n.rules.Sites["*"]
 		}
-	} else {
-		customRe = nil
+		if ok {
+			// Copy the site rules, the inferred rules are added to the copy.
+			siteCopy := *site
+			n.site = &siteCopy
+			return
+		}
+	}
+
+	n.site = &SiteRules{}
+	for _, key := range keyStore[fullDomain] {
+		r := newKeyRule(key)
+		r.compile()
+		n.site.Rules = append(n.site.Rules, r)
 	}
 }
 
-func GetAbsolutePath(base string, path string) string {
+// GetAbsolutePath resolves path against base, e.g.,
+// ("/directory/", "../search?q=go") -> "/search?q=go".
+func GetAbsolutePath(base string, path string) (string, error) {
 	p, err := url.Parse(path)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	b, err := url.Parse(base)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	res := b.ResolveReference(p)
 	if res.RawQuery == "" {
-		return res.Path
+		return res.Path, nil
 	} else {
-		return res.Path + "?" + res.RawQuery
+		return res.Path + "?" + res.RawQuery, nil
 	}
 }
 
@@ -39,9 +39,9 @@ func TestGetPattern(t *testing.T) {
 
 func testGetFullDomainName(t *testing.T, url string, res string) {
 	t.Helper()
-	myRes := GetFullDomainName(url)
-	if myRes != res {
-		t.Errorf("GetFullDomainName(%s) = %s, supposed to be %s", url, myRes, res)
+	myRes, err := GetFullDomainName(url)
+	if err != nil || myRes != res {
+		t.Errorf("GetFullDomainName(%s) = %s, %v, supposed to be %s", url, myRes, err, res)
 	}
 }
 
@@ -49,13 +49,20 @@ func TestGetFullDomainName(t *testing.T) {
 	testGetFullDomainName(t, "https://www.example.com/", "www.example.com")
 	testGetFullDomainName(t, "https://custom.example.com/", "custom.example.com")
 	testGetFullDomainName(t, "https://abc.github.io/", "abc.github.io")
+	testGetFullDomainName(t, "http://localhost:8080/", "localhost:8080")
+
+	for _, url := range []string{"www.example.com", "https://", "https:///"} {
+		if _, err := GetFullDomainName(url); err == nil {
+			t.Errorf("GetFullDomainName(%s) succeeded, supposed to fail", url)
+		}
+	}
 }
 
 func testGetDomainName(t *testing.T, url string, res string) {
 	t.Helper()
-	myRes := GetDomainName(url)
-	if myRes != res {
-		t.Errorf("GetDomainName(%s) = %s, supposed to be %s", url, myRes, res)
+	myRes, err := GetDomainName(url)
+	if err != nil || myRes != res {
+		t.Errorf("GetDomainName(%s) = %s, %v, supposed to be %s", url, myRes, err, res)
 	}
 }
 
@@ -64,6 +71,11 @@ func TestGetDomainName(t *testing.T) {
 	testGetDomainName(t, "https://custom.example.com/", "example.com")
 	testGetDomainName(t, "https://abc.github.io/", "github.io")
 	testGetDomainName(t, "http://test.net/", "test.net")
+	testGetDomainName(t, "http://localhost:8080/", "localhost:8080")
+
+	if _, err := GetDomainName("localhost"); err == nil {
+		t.Errorf("GetDomainName(localhost) succeeded, supposed to fail")
+	}
 }
 
 func testStripDomainName(t *testing.T, url string, domain string, res string) {
@@ -96,11 +108,32 @@ func TestCustomRe(t *testing.T) {
 	testGetPattern(t, "/catalogue/category/books/travel_2/index.html/", "/catalogue/*/books/*/*.html/")
 }
 
+func TestNormalizer(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"sites": {"a.example.com": {"rules": [{"key": "author"}]}, "b.example.com": {"rules": [{"key": "products"}]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewNormalizer()
+	a.SetRules(rs)
+	a.GenerateCustomRe("a.example.com")
+	b := NewNormalizer()
+	b.SetRules(rs)
+	b.GenerateCustomRe("b.example.com")
+
+	if res := a.GetPattern("/author/alice/products/abc"); res != "/author/*/products/abc" {
+		t.Errorf("a.GetPattern() = %s, supposed to be %s", res, "/author/*/products/abc")
+	}
+	if res := b.GetPattern("/author/alice/products/abc"); res != "/author/alice/products/*" {
+		t.Errorf("b.GetPattern() = %s, supposed to be %s", res, "/author/alice/products/*")
+	}
+}
+
 func testGetAbsolutePath(t *testing.T, base string, path string, res string) {
 	t.Helper()
-	myRes := GetAbsolutePath(base, path)
-	if myRes != res {
-		t.Errorf("GetAbsolutePath(%s, %s) = %s, supposed to be %s", base, path, myRes, res)
+	myRes, err := GetAbsolutePath(base, path)
+	if err != nil || myRes != res {
+		t.Errorf("GetAbsolutePath(%s, %s) = %s, %v, supposed to be %s", base, path, myRes, err, res)
 	}
 }
 
@@ -113,6 +146,10 @@ func TestGetAbsolutePath(t *testing.T) {
 	testGetAbsolutePath(t, "https://shop.example.com/directory?id=123", "", "/directory?id=123")
 	testGetAbsolutePath(t, "https://example.com/page.html#tag", "", "/page.html")
 	testGetAbsolutePath(t, "https://example.com/page.html#tag", "//http://test.com/directory", "//test.com/directory")
+
+	if _, err := GetAbsolutePath("http://example.com/directory/", "%zz"); err == nil {
+		t.Errorf("GetAbsolutePath(%s, %s) should fail", "http://example.com/directory/", "%zz")
+	}
 }
 
 func testIsHtml(t *testing.T, path string, res bool) {
@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"fmt"
+	pathpkg "path"
+	"sort"
+	"strings"
+)
+
+// QueryPolicy normalises the query string of a path.
+type QueryPolicy struct {
+	// Sort sorts the keys, so "?b=2&a=1" is the same as "?a=1&b=2".
+	Sort bool `json:"sort,omitempty"`
+	// Ignore are the keys to drop like "utm_*" or "sessionid", in the
+	// syntax of path.Match().
+	Ignore []string `json:"ignore,omitempty"`
+	// Keep are the routing keys like "page" or "tab" whose values are kept,
+	// the values of the other keys are replaced with "*".
+	Keep []string `json:"keep,omitempty"`
+	// Collapse keeps only the first one of the repeated keys.
+	Collapse bool `json:"collapse,omitempty"`
+}
+
+type queryParam struct {
+	key   string
+	value string
+	equal bool
+}
+
+func (qp *QueryPolicy) validate() error {
+	for _, key := range qp.Ignore {
+		if _, err := pathpkg.Match(key, ""); err != nil {
+			return fmt.Errorf("invalid ignored key %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func matchKey(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := pathpkg.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply normalises query without the leading "?", the values are replaced
+// with "*" only when hideValues is true.
+// "b=2&utm_source=x&a=1&a=2" -> "a=*&b=*"
+func (qp *QueryPolicy) apply(query string, hideValues bool) string {
+	// The crawler adds "/" to the end of each path.
+	query = strings.TrimSuffix(query, "/")
+
+	params := []queryParam{}
+	seen := map[string]bool{}
+	for _, s := range strings.Split(query, "&") {
+		if s == "" {
+			continue
+		}
+
+		p := queryParam{}
+		p.key = s
+		if i := strings.Index(s, "="); i != -1 {
+			p.key, p.value, p.equal = s[:i], s[i+1:], true
+		}
+
+		if matchKey(qp.Ignore, p.key) {
+			continue
+		}
+		if qp.Collapse {
+			if seen[p.key] {
+				continue
+			}
+			seen[p.key] = true
+		}
+		if hideValues && p.equal && !matchKey(qp.Keep, p.key) {
+			p.value = "*"
+		}
+		params = append(params, p)
+	}
+
+	if qp.Sort {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].key < params[j].key
+		})
+	}
+
+	res := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.equal {
+			res = append(res, p.key+"="+p.value)
+		} else {
+			res = append(res, p.key)
+		}
+	}
+	return strings.Join(res, "&")
+}
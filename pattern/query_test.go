@@ -0,0 +1,41 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import "testing"
+
+func TestQueryPolicy(t *testing.T) {
+	rs, err := ParseRules([]byte(`{"sites": {"*": {"rules": [], "query": {"sort": true, "ignore": ["utm_*", "sessionid"], "keep": ["page", "tab"], "collapse": true}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNormalizer()
+	n.SetRules(rs)
+	n.GenerateCustomRe("example.com")
+
+	testNormalizerPattern(t, n, "/list?b=2&a=1", "/list?a=*&b=*")
+	testNormalizerPattern(t, n, "/list?a=1&b=2", "/list?a=*&b=*")
+	testNormalizerPattern(t, n, "/list?utm_source=x&id=5&sessionid=abc", "/list?id=*")
+	testNormalizerPattern(t, n, "/list?utm_source=x/", "/list/")
+	testNormalizerPattern(t, n, "/list?utm_source=x", "/list")
+	testNormalizerPattern(t, n, "/list5?page=2&tab=reviews&id=5/", "/list*?id=*&page=2&tab=reviews")
+	testNormalizerPattern(t, n, "/list?c=1&c=2&flag", "/list?c=*&flag")
+	testNormalizerPattern(t, n, "/lifestyle-sale/vip/gd2.html?saleType=2&channel=lifestyle", "/lifestyle-sale/vip/*.html?channel=*&saleType=*")
+}
+
+func TestInvalidQueryPolicy(t *testing.T) {
+	testParseRulesError(t, `{"sites": {"*": {"rules": [], "query": {"ignore": ["utm_["]}}}}`, `site "*": query: invalid ignored key "utm_["`)
+}
@@ -0,0 +1,243 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rule collapses a part of the path. It is either a key like "author|tag",
+// which turns "/author/alice" into "/author/*", or a regex with its
+// replacement template like "$1/*$2".
+type Rule struct {
+	Name    string `json:"name,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Replace string `json:"replace,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// SiteRules are the rules of a site, applied in their order before the
+// built-in rules.
+type SiteRules struct {
+	Rules []*Rule `json:"rules"`
+	// Digits enables the built-in "/page5" -> "/page*" rule, true if omitted.
+	Digits *bool `json:"digits,omitempty"`
+	// QueryValues enables the built-in "?id=123" -> "?id=*" rule, true if
+	// omitted.
+	QueryValues *bool `json:"queryValues,omitempty"`
+	// Query is the query string policy, the query is only handled by the
+	// QueryValues rule if omitted.
+	Query *QueryPolicy `json:"query,omitempty"`
+	// Classifiers are the names of the classifiers which replace the typed
+	// segments like "/user/123" -> "/user/{int}", tried in the order they
+	// are registered. No segment is classified if omitted.
+	Classifiers []string `json:"classifiers,omitempty"`
+
+	classifiers []*Classifier
+}
+
+// Rules are the collapsing rules per host. The host "*" matches the hosts
+// without their own rules.
+type Rules struct {
+	Sites map[string]*SiteRules `json:"sites"`
+}
+
+// templateGroupRe matches the group references of a replacement template like
+// "$1", "${1}", "$name" and "${name}". "$$" is an escaped "$", and "$1x" is the
+// group "1x" for Expand().
+var templateGroupRe = regexp.MustCompile(`\$(\$|\{\w+\}|\w+)`)
+
+func newKeyRule(key string) *Rule {
+	r := Rule{}
+	r.Key = key
+	return &r
+}
+
+func (r *Rule) compile() error {
+	if r.Key != "" && r.Regex != "" {
+		return fmt.Errorf("only one of \"key\" and \"regex\" can be set")
+	}
+
+	if r.Key != "" {
+		re, err := regexp.Compile("(" + r.Key + ")/[^/]+(.*)")
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %v", r.Key, err)
+		}
+		r.re = re
+		return nil
+	}
+
+	if r.Regex == "" {
+		return fmt.Errorf("one of \"key\" and \"regex\" must be set")
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %v", r.Regex, err)
+	}
+	for _, m := range templateGroupRe.FindAllStringSubmatch(r.Replace, -1) {
+		if m[1] == "$" {
+			continue
+		}
+		ref := strings.Trim(m[1], "{}")
+		i := strings.IndexFunc(ref, isNotDigit)
+		if i == -1 {
+			group, _ := strconv.Atoi(ref)
+			if group > re.NumSubexp() {
+				return fmt.Errorf("replacement %q refers to group %d, but regex %q has %d groups", r.Replace, group, r.Regex, re.NumSubexp())
+			}
+			continue
+		}
+		if i > 0 && ref == m[1] {
+			return fmt.Errorf("replacement %q refers to group %q, write \"${%s}%s\" instead", r.Replace, ref, ref[:i], ref[i:])
+		}
+		if !hasSubexpName(re, ref) {
+			return fmt.Errorf("replacement %q refers to group %q, but regex %q has no such named group", r.Replace, ref, r.Regex)
+		}
+	}
+	r.re = re
+	return nil
+}
+
+func isNotDigit(c rune) bool {
+	return c < '0' || c > '9'
+}
+
+func hasSubexpName(re *regexp.Regexp, name string) bool {
+	for _, n := range re.SubexpNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply applies r to path.
+func (r *Rule) Apply(path string) string {
+	if r.Key != "" {
+		return r.re.ReplaceAllString(path, "$1/*$2")
+	}
+	return r.re.ReplaceAllString(path, r.Replace)
+}
+
+func (sr *SiteRules) digits() bool {
+	return sr.Digits == nil || *sr.Digits
+}
+
+func (sr *SiteRules) queryValues() bool {
+	return sr.QueryValues == nil || *sr.QueryValues
+}
+
+// Validate compiles all the rules, the error points at the offending rule.
+func (rs *Rules) Validate() error {
+	hosts := make([]string, 0, len(rs.Sites))
+	for host := range rs.Sites {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		site := rs.Sites[host]
+		if site == nil {
+			return fmt.Errorf("site %q: no rules", host)
+		}
+		classifiers, err := getClassifiers(site.Classifiers)
+		if err != nil {
+			return fmt.Errorf("site %q: %v", host, err)
+		}
+		site.classifiers = classifiers
+		if site.Query != nil {
+			if err := site.Query.validate(); err != nil {
+				return fmt.Errorf("site %q: query: %v", host, err)
+			}
+		}
+		for i, r := range site.Rules {
+			if r == nil {
+				return fmt.Errorf("site %q: rule #%d: empty rule", host, i+1)
+			}
+			if err := r.compile(); err != nil {
+				if r.Name != "" {
+					return fmt.Errorf("site %q: rule #%d (%s): %v", host, i+1, r.Name, err)
+				}
+				return fmt.Errorf("site %q: rule #%d: %v", host, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseRules parses and validates the rules in JSON like:
+//
+//	{
+//	  "sites": {
+//	    "quotes.toscrape.com": {
+//	      "rules": [
+//	        {"key": "author|tag"},
+//	        {"name": "dates", "regex": "/\\d{4}/\\d{2}/\\d{2}(/.*)", "replace": "/*/*/*$1"}
+//	      ],
+//	      "digits": true,
+//	      "queryValues": false,
+//	      "classifiers": ["uuid", "date", "int"],
+//	      "query": {"sort": true, "ignore": ["utm_*"], "keep": ["page"], "collapse": true}
+//	    }
+//	  }
+//	}
+func ParseRules(data []byte) (*Rules, error) {
+	rs := Rules{}
+	if err := json.Unmarshal(data, &rs); err != nil {
+		if e, ok := err.(*json.SyntaxError); ok {
+			line := bytes.Count(data[:e.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		return nil, err
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// LoadRules reads the rules from the JSON file at path.
+func LoadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rs, nil
+}
+
+// SaveRules writes the rules as JSON to the file at path.
+func SaveRules(rs *Rules, path string) error {
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"strings"
+	"testing"
+)
+
+func testParseRulesError(t *testing.T, data string, msg string) {
+	t.Helper()
+	_, err := ParseRules([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), msg) {
+		t.Errorf("ParseRules(%s) error = %v, supposed to contain %s", data, err, msg)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rs, err := ParseRules([]byte(`{
+  "sites": {
+    "example.com": {
+      "rules": [
+        {"key": "author"},
+        {"name": "dates", "regex": "/\\d{4}/\\d{2}/\\d{2}(/.*)", "replace": "/date$1"}
+      ],
+      "digits": false
+    },
+    "*": {
+      "rules": [{"key": "products"}],
+      "queryValues": false
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNormalizer()
+	n.SetRules(rs)
+
+	n.GenerateCustomRe("example.com")
+	if res := n.GetPattern("/author/alice/2018/01/02/title5"); res != "/author/*/date/title5" {
+		t.Errorf("GetPattern() = %s, supposed to be %s", res, "/author/*/date/title5")
+	}
+
+	n.GenerateCustomRe("other.com")
+	if res := n.GetPattern("/products/abc/list?id=5"); res != "/products/*/list?id=*" {
+		t.Errorf("GetPattern() = %s, supposed to be %s", res, "/products/*/list?id=*")
+	}
+	if res := n.GetPattern("/author/alice?id=abc"); res != "/author/alice?id=abc" {
+		t.Errorf("GetPattern() = %s, supposed to be %s", res, "/author/alice?id=abc")
+	}
+}
+
+func TestParseRulesError(t *testing.T) {
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"key": "a"}, {"name": "bad", "regex": "(a"}]}}}`, `site "example.com": rule #2 (bad): invalid regex`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"regex": "(a)", "replace": "$2"}]}}}`, `rule #1: replacement "$2" refers to group 2`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"regex": "(a)", "replace": "$1x"}]}}}`, `write "${1}x"`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"regex": "(?P<name>a)", "replace": "$nam"}]}}}`, `refers to group "nam", but regex "(?P<name>a)" has no such named group`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"regex": "(a)", "replace": "${name}"}]}}}`, `refers to group "name"`)
+	// The sites are validated in the order of their hosts.
+	testParseRulesError(t, `{"sites": {"b.example.com": {"rules": [{}]}, "a.example.com": {"rules": [{}]}}}`, `site "a.example.com"`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{"key": "a", "regex": "b"}]}}}`, `only one of "key" and "regex"`)
+	testParseRulesError(t, `{"sites": {"example.com": {"rules": [{}]}}}`, `one of "key" and "regex" must be set`)
+	testParseRulesError(t, "{\n\"sites\": {,}}", "line 2")
+
+	for _, replace := range []string{"${1}x", "$$1x", "$1/x", "$name/x", "${name}x", "$$name"} {
+		if _, err := ParseRules([]byte(`{"sites": {"*": {"rules": [{"regex": "(?P<name>a)", "replace": "` + replace + `"}]}}}`)); err != nil {
+			t.Errorf("ParseRules() with replacement %q error = %v", replace, err)
+		}
+	}
+}
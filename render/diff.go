@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"github.com/hsluoyz/logdance/graph"
+)
+
+func newDiffGraph(d *graph.GraphDiff) *Graph {
+	g := Graph{}
+	g.Nodes = make([]Node, 0, len(d.Pages))
+	g.Links = make([]Link, 0, len(d.Links))
+
+	inDegrees := make(map[int]int)
+	outDegrees := make(map[int]int)
+	for _, dl := range d.Links {
+		// The removed links keep their old weights.
+		l := newLink(dl.Source, dl.Target, dl.NewWeight)
+		if dl.Status == graph.DiffRemoved {
+			l.Weight = dl.OldWeight
+		}
+		l.Diff = dl.Status
+		l.OldWeight = dl.OldWeight
+		g.Links = append(g.Links, l)
+
+		inDegrees[dl.Target]++
+		outDegrees[dl.Source]++
+	}
+
+	for _, p := range d.Pages {
+		page := graph.Page{Name: p.Name}
+		n := newNode(p.Id, p.Name, page.Category())
+		n.Diff = p.Status
+		n.OldName = p.OldName
+		n.InDegree = inDegrees[p.Id]
+		n.OutDegree = outDegrees[p.Id]
+		g.Nodes = append(g.Nodes, n)
+	}
+	return &g
+}
+
+// GenerateDiffJson writes the diff d of two page graphs as D3 JSON to the file
+// at path. The pages and links of both graphs are written with their diff
+// statuses, so index.html colours them.
+func GenerateDiffJson(d *graph.GraphDiff, path string) error {
+	return newDiffGraph(d).save(path)
+}
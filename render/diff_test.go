@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+func TestGenerateDiffJson(t *testing.T) {
+	old := graph.NewGraph()
+	old.AddPage("/")
+	old.AddLink("/", "/a-old/")
+	old.AddLink("/", "/b/")
+	next := graph.NewGraph()
+	next.AddPage("/")
+	next.AddLink("/", "/a/")
+	next.AddLink("/", "/a/")
+	next.AddLink("/", "/c/")
+	next.AddRedirectPage("/a-old/", "/a/")
+
+	path := filepath.Join(t.TempDir(), "webgraph-diff.json")
+	if err := GenerateDiffJson(graph.Diff(old, next), path); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadGraph(t, path)
+	nodes := map[string]string{"/": "same", "/a/": "changed", "/c/": "added", "/b/": "removed"}
+	for _, n := range g.Nodes {
+		if n.Diff != nodes[n.Name] {
+			t.Errorf("node %s: Diff = %q, supposed to be %q", n.Name, n.Diff, nodes[n.Name])
+		}
+	}
+	if a := g.Nodes[1]; a.Name != "/a/" || a.OldName != "/a-old/" {
+		t.Errorf("node 1 = %+v, supposed to be /a/ renamed from /a-old/", a)
+	}
+	if home := g.Nodes[0]; home.Category != "home" || home.OutDegree != 3 {
+		t.Errorf("node / = %+v, supposed to be the home linking to 3 pages", home)
+	}
+
+	links := map[[2]int]Link{
+		{0, 1}: {Weight: 2, Diff: "changed", OldWeight: 1},
+		{0, 2}: {Weight: 1, Diff: "added"},
+		{0, 3}: {Weight: 1, Diff: "removed", OldWeight: 1},
+	}
+	if len(g.Links) != len(links) {
+		t.Errorf("len(Links) = %d, supposed to be %d", len(g.Links), len(links))
+	}
+	for _, l := range g.Links {
+		res := links[[2]int{l.Source, l.Target}]
+		if l.Weight != res.Weight || l.Diff != res.Diff || l.OldWeight != res.OldWeight {
+			t.Errorf("link %d -> %d = %+v, supposed to be %+v", l.Source, l.Target, l, res)
+		}
+	}
+}
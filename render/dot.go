@@ -0,0 +1,149 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+// getTopSegment gets the top-level segment of a path, e.g.,
+// "/author/*/" -> "author", "/search?q=*" -> "search", "/" -> "".
+func getTopSegment(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.IndexAny(path, "/?"); i != -1 {
+		path = path[:i]
+	}
+	return path
+}
+
+func dotEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, `"`, `\"`, -1)
+}
+
+func dotQuote(s string) string {
+	return `"` + dotEscape(s) + `"`
+}
+
+// getDotLabel gets the label of page with its name and aliases on lines.
+func getDotLabel(page *graph.Page) string {
+	lines := []string{dotEscape(page.Name)}
+	for _, alias := range page.Aliases {
+		lines = append(lines, "aka "+dotEscape(alias))
+	}
+	return `"` + strings.Join(lines, `\n`) + `"`
+}
+
+func writeDotPage(b *bytes.Buffer, page *graph.Page, indent string) {
+	fmt.Fprintf(b, "%sp%d [label=%s", indent, page.Id, getDotLabel(page))
+	if page.Name == "/" {
+		b.WriteString(`, style="rounded,bold"`)
+	}
+	b.WriteString("];\n")
+}
+
+// getSortedTargets returns the targets of the links of page, sorted.
+func getSortedTargets(page *graph.Page) []int {
+	targets := make([]int, 0, len(page.Links))
+	for target := range page.Links {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+// WriteDot writes the page graph pg in the Graphviz DOT language to w, for
+// "dot" or "sfdp". The pages are labeled with their aliases and clustered by
+// their top-level path segments, and the redirections are drawn as dashed
+// edges between the names of the pages.
+func WriteDot(w io.Writer, pg *graph.Graph) error {
+	pages := pg.Pages()
+
+	b := bytes.Buffer{}
+	b.WriteString("digraph webgraph {\n")
+	b.WriteString("\tgraph [rankdir=LR, overlap=false, fontname=\"sans-serif\"];\n")
+	b.WriteString("\tnode [shape=box, style=rounded, fontname=\"sans-serif\"];\n")
+	b.WriteString("\tedge [color=gray40];\n")
+
+	// Only the segments of two pages or more become clusters.
+	segments := []string{}
+	clusters := make(map[string][]*graph.Page)
+	for _, page := range pages {
+		segment := getTopSegment(page.Name)
+		if _, ok := clusters[segment]; !ok {
+			segments = append(segments, segment)
+		}
+		clusters[segment] = append(clusters[segment], page)
+	}
+	for _, segment := range segments {
+		cluster := clusters[segment]
+		if segment == "" || len(cluster) < 2 {
+			for _, page := range cluster {
+				writeDotPage(&b, page, "\t")
+			}
+			continue
+		}
+
+		fmt.Fprintf(&b, "\tsubgraph %s {\n", dotQuote("cluster_"+segment))
+		fmt.Fprintf(&b, "\t\tlabel=%s;\n", dotQuote("/"+segment))
+		b.WriteString("\t\tstyle=dashed;\n")
+		for _, page := range cluster {
+			writeDotPage(&b, page, "\t\t")
+		}
+		b.WriteString("\t}\n")
+	}
+
+	for _, page := range pages {
+		for _, target := range getSortedTargets(page) {
+			fmt.Fprintf(&b, "\tp%d -> p%d", page.Id, target)
+			if count := page.Links[target]; count > 1 {
+				fmt.Fprintf(&b, " [weight=%d, penwidth=%.1f, label=\"%d\"]", count, 1+math.Log2(float64(count)), count)
+			}
+			b.WriteString(";\n")
+		}
+	}
+
+	// The names of a page other than its own name are drawn as plain text.
+	for _, page := range pages {
+		nodes := map[string]string{page.Name: fmt.Sprintf("p%d", page.Id)}
+		getNode := func(name string) string {
+			if node, ok := nodes[name]; ok {
+				return node
+			}
+
+			node := fmt.Sprintf("p%d_%d", page.Id, len(nodes))
+			nodes[name] = node
+			fmt.Fprintf(&b, "\t%s [label=%s, shape=plaintext, fontcolor=gray40];\n", node, dotQuote(name))
+			return node
+		}
+
+		for _, r := range page.Redirects {
+			from := getNode(r.From)
+			to := getNode(r.To)
+			fmt.Fprintf(&b, "\t%s -> %s [style=dashed, color=gray60, label=\"redirect\"];\n", from, to)
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+func newTestGraph() *graph.Graph {
+	pg := graph.NewGraph()
+	pg.AddPage("/")
+	pg.AddRedirectPage("/", "/home/")
+	pg.AddLink("/", "/author/*/")
+	pg.AddLink("/", "/author/*/")
+	pg.AddLink("/", "/tag/*/")
+	pg.AddLink("/author/*/", "/author/*/books/")
+	pg.AddLink("/tag/*/", "/about\"/")
+	return pg
+}
+
+func TestGetTopSegment(t *testing.T) {
+	tests := map[string]string{"/": "", "/author/*/": "author", "/search?q=*": "search", "/index.html/": "index.html"}
+	for path, res := range tests {
+		if segment := getTopSegment(path); segment != res {
+			t.Errorf("getTopSegment(%q) = %q, supposed to be %q", path, segment, res)
+		}
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := WriteDot(&b, newTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+
+	lines := []string{
+		"digraph webgraph {\n",
+		"\tp0 [label=\"/\\naka /home/\", style=\"rounded,bold\"];\n",
+		"\tsubgraph \"cluster_author\" {\n\t\tlabel=\"/author\";\n\t\tstyle=dashed;\n\t\tp1 [label=\"/author/*/\"];\n\t\tp3 [label=\"/author/*/books/\"];\n\t}\n",
+		"\tp2 [label=\"/tag/*/\"];\n",
+		"\tp4 [label=\"/about\\\"/\"];\n",
+		"\tp0 -> p1 [weight=2, penwidth=2.0, label=\"2\"];\n",
+		"\tp0 -> p2;\n",
+		"\tp0_1 [label=\"/home/\", shape=plaintext, fontcolor=gray40];\n",
+		"\tp0 -> p0_1 [style=dashed, color=gray60, label=\"redirect\"];\n",
+	}
+	for _, line := range lines {
+		if !strings.Contains(dot, line) {
+			t.Errorf("WriteDot() = %s, supposed to contain %q", dot, line)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+// Format is an output format of the page graph.
+type Format struct {
+	Name string
+	// Exts are the file extensions of the format like ".dot", the first one
+	// is the usual one.
+	Exts  []string
+	Write func(w io.Writer, pg *graph.Graph) error
+	// Generate writes the files of the formats of several files like CSV at
+	// path, instead of Write.
+	Generate func(pg *graph.Graph, path string) error
+	// Read reads the page graph back if the format can be read, and Load
+	// reads the files of the formats of several files instead.
+	Read func(r io.Reader) (*graph.Graph, error)
+	Load func(path string) (*graph.Graph, error)
+}
+
+var formatMap map[string]*Format
+
+func init() {
+	formatMap = make(map[string]*Format)
+
+	RegisterFormat("json", []string{".json"}, WriteJson)
+	RegisterFormat("dot", []string{".dot", ".gv"}, WriteDot)
+	RegisterFormat("graphml", []string{".graphml"}, WriteGraphml)
+	RegisterFormat("gexf", []string{".gexf"}, WriteGexf)
+	RegisterFormat("mermaid", []string{".mmd", ".mermaid"}, WriteMermaid)
+	RegisterFormat("plantuml", []string{".puml", ".plantuml"}, WritePlantuml)
+	registerTableFormat("csv", ".csv", ',')
+	registerTableFormat("tsv", ".tsv", '\t')
+
+	RegisterReader("json", ReadJson)
+	RegisterReader("graphml", ReadGraphml)
+	RegisterReader("gexf", ReadGexf)
+}
+
+// RegisterFormat registers the format name with its file extensions, it
+// replaces the format of the same name.
+func RegisterFormat(name string, exts []string, write func(w io.Writer, pg *graph.Graph) error) {
+	f := Format{}
+	f.Name = name
+	f.Exts = exts
+	f.Write = write
+	formatMap[name] = &f
+}
+
+// RegisterReader registers the reader of the registered format name, so the
+// graphs written in it can be read back by Load().
+func RegisterReader(name string, read func(r io.Reader) (*graph.Graph, error)) {
+	formatMap[name].Read = read
+}
+
+// getTablePath gets the path of a table from the path of the output, e.g.,
+// "webgraph.csv" -> "webgraph-nodes.csv".
+func getTablePath(path string, table string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + table + ext
+}
+
+// registerTableFormat registers the format name of the node and edge lists,
+// the fields are delimited by comma.
+func registerTableFormat(name string, ext string, comma rune) {
+	f := Format{}
+	f.Name = name
+	f.Exts = []string{ext}
+	f.Generate = func(pg *graph.Graph, path string) error {
+		buf := bytes.Buffer{}
+		if err := pg.WriteNodesCsv(&buf, comma); err != nil {
+			return err
+		}
+		if err := writeFile(getTablePath(path, "nodes"), buf.Bytes()); err != nil {
+			return err
+		}
+
+		buf.Reset()
+		if err := pg.WriteEdgesCsv(&buf, comma); err != nil {
+			return err
+		}
+		if err := writeFile(getTablePath(path, "edges"), buf.Bytes()); err != nil {
+			return err
+		}
+
+		buf.Reset()
+		if err := pg.WriteRedirectsCsv(&buf, comma); err != nil {
+			return err
+		}
+		return writeFile(getTablePath(path, "redirects"), buf.Bytes())
+	}
+	f.Load = func(path string) (*graph.Graph, error) {
+		nodes, err := os.Open(getTablePath(path, "nodes"))
+		if err != nil {
+			return nil, err
+		}
+		defer nodes.Close()
+		edges, err := os.Open(getTablePath(path, "edges"))
+		if err != nil {
+			return nil, err
+		}
+		defer edges.Close()
+		// The redirections are optional.
+		var redirects io.Reader
+		if f, err := os.Open(getTablePath(path, "redirects")); err == nil {
+			defer f.Close()
+			redirects = f
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		pg, err := graph.ReadCsv(nodes, edges, redirects, comma)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return pg, nil
+	}
+	formatMap[name] = &f
+}
+
+// GetFormat returns the format of name.
+func GetFormat(name string) (*Format, error) {
+	f, ok := formatMap[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, supported formats: %v", name, GetFormatNames())
+	}
+	return f, nil
+}
+
+// GetFormatNames returns the names of the registered formats, sorted.
+func GetFormatNames() []string {
+	names := make([]string, 0, len(formatMap))
+	for name := range formatMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetReadableFormatNames returns the names of the formats which can be read,
+// sorted.
+func GetReadableFormatNames() []string {
+	names := []string{}
+	for _, name := range GetFormatNames() {
+		if f := formatMap[name]; f.Read != nil || f.Load != nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetFormatByPath returns the format of the extension of path, or the JSON
+// format if the extension is unknown.
+func GetFormatByPath(path string) *Format {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range formatMap {
+		for _, e := range f.Exts {
+			if e == ext {
+				return f
+			}
+		}
+	}
+	return formatMap["json"]
+}
+
+// Options are the options of the output, so the diagrams like Mermaid stay
+// readable. The zero options keep the whole graph.
+type Options struct {
+	// MaxNodes and MaxEdges are the budgets of the pages and links, the most
+	// visited pages and the heaviest links are kept. 0 means no limit.
+	MaxNodes int
+	MaxEdges int
+	// MinWeight prunes the links lighter than it.
+	MinWeight int
+}
+
+// apply returns the graph pruned by the options.
+func (opts *Options) apply(pg *graph.Graph) *graph.Graph {
+	if opts == nil || *opts == (Options{}) {
+		return pg
+	}
+	return pg.Prune(opts.MaxNodes, opts.MaxEdges, opts.MinWeight)
+}
+
+// getFormat returns the format of name, or of the extension of path if name
+// is empty.
+func getFormat(path string, name string) (*Format, error) {
+	if name == "" {
+		return GetFormatByPath(path), nil
+	}
+	return GetFormat(name)
+}
+
+// Generate writes the page graph pg pruned by opts to the file at path in the
+// format of name, or of the extension of path if name is empty.
+func Generate(pg *graph.Graph, path string, name string, opts *Options) error {
+	f, err := getFormat(path, name)
+	if err != nil {
+		return err
+	}
+
+	pg = opts.apply(pg)
+	if f.Generate != nil {
+		return f.Generate(pg, path)
+	}
+
+	buf := bytes.Buffer{}
+	if err := f.Write(&buf, pg); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// Load reads the page graph from the file at path in the format of name, or of
+// the extension of path if name is empty, like the graphs written by
+// Generate(). The pages keep their ids, names, aliases and links.
+func Load(path string, name string) (*graph.Graph, error) {
+	f, err := getFormat(path, name)
+	if err != nil {
+		return nil, err
+	}
+	if f.Load != nil {
+		return f.Load(path)
+	}
+	if f.Read == nil {
+		return nil, fmt.Errorf("the %s format cannot be read, readable formats: %v", f.Name, GetReadableFormatNames())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pg, err := f.Read(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return pg, nil
+}
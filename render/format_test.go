@@ -0,0 +1,133 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+func TestGetFormatByPath(t *testing.T) {
+	tests := map[string]string{"webgraph.json": "json", "site.GV": "dot", "site.gexf": "gexf", "site.mmd": "mermaid", "site": "json"}
+	for path, res := range tests {
+		if f := GetFormatByPath(path); f.Name != res {
+			t.Errorf("GetFormatByPath(%q) = %s, supposed to be %s", path, f.Name, res)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site.txt")
+	opts := Options{MinWeight: 2}
+	if err := Generate(newTestGraph(), path, "mermaid", &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := "flowchart LR\n    n0([\"/\"])\n    n1[\"/author/*/\"]\n    n0 -->|2| n1\n"
+	if string(data) != res {
+		t.Errorf("Generate() = %s, supposed to be %s", data, res)
+	}
+
+	if err := Generate(newTestGraph(), path, "svg", nil); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("Generate(svg) = %v, supposed to be an unknown output format", err)
+	}
+}
+
+func TestGenerateTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webgraph.tsv")
+	if err := Generate(newTestGraph(), path, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := map[string]int{"nodes": 5, "edges": 4, "redirects": 1}
+	for table, n := range rows {
+		data, err := ioutil.ReadFile(getTablePath(path, table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(string(data), "\n"); len(lines) != n+2 || !strings.Contains(lines[1], "\t") {
+			t.Errorf("webgraph-%s.tsv = %s, supposed to be a header and %d rows of TSV", table, data, n)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t0 := time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC)
+	pg := newTestGraph()
+	pg.AddHit("/tag/*/", t0, 200)
+	pg.AddHit("/tag/*/", t0.Add(time.Hour), 404)
+	// The alias has a space.
+	pg.AddLink("/tag/*/", "/all tags/")
+	pg.AddRedirectPage("/all tags/", "/tags/")
+	dir := t.TempDir()
+
+	// Each format keeps the ids, names, aliases and links, and some of the
+	// other fields.
+	tests := []struct {
+		path  string
+		strip func(page *graph.Page)
+	}{
+		{"webgraph.json", func(page *graph.Page) {}},
+		{"webgraph.graphml", func(page *graph.Page) {
+			page.Redirects = nil
+		}},
+		{"webgraph.gexf", func(page *graph.Page) {
+			page.Redirects = nil
+		}},
+		{"webgraph.csv", func(page *graph.Page) {
+			page.FirstSeen, page.LastSeen, page.Statuses = time.Time{}, time.Time{}, nil
+		}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.path)
+		if err := Generate(pg, path, "", nil); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path, "")
+		if err != nil {
+			t.Errorf("Load(%s) = %v", test.path, err)
+			continue
+		}
+
+		want := pg.Pages()
+		for _, page := range want {
+			test.strip(page)
+		}
+		if pages := loaded.Pages(); !reflect.DeepEqual(pages, want) {
+			t.Errorf("Load(%s) = %+v, supposed to be %+v", test.path, pages, want)
+		}
+	}
+
+	path := filepath.Join(dir, "webgraph.dot")
+	if err := Generate(pg, path, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), "cannot be read") {
+		t.Errorf("Load(webgraph.dot) = %v, supposed to be an unreadable format", err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json"), ""); err == nil {
+		t.Errorf("Load(missing.json) succeeded, supposed to fail")
+	}
+}
@@ -15,9 +15,13 @@
 package render
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/hsluoyz/logdance/graph"
 )
@@ -25,31 +29,156 @@ import (
 type Graph struct {
 	Nodes []Node `json:"nodes"`
 	Links []Link `json:"links"`
+	// Windows are the time windows of the graph, the links have their counts
+	// in each window.
+	Windows []*Window `json:"windows,omitempty"`
 }
 
-func GenerateJson() {
+// Window is a time window [Start, End) of a page graph, Graph is the graph of
+// the window only.
+type Window struct {
+	Start time.Time    `json:"start"`
+	End   time.Time    `json:"end"`
+	Graph *graph.Graph `json:"-"`
+}
+
+func newGraph(pg *graph.Graph) *Graph {
 	g := Graph{}
 	g.Nodes = make([]Node, 0)
 	g.Links = make([]Link, 0)
 
-	for _, page := range graph.PageList {
-		if page.Name == "/" {
-			g.Nodes = append(g.Nodes, newNode(page.Id, page.Name, "home"))
-		} else {
-			g.Nodes = append(g.Nodes, newNode(page.Id, page.Name, "page"))
+	inDegrees := make(map[int]int)
+	for _, page := range pg.Pages() {
+		n := newNode(page.Id, page.Name, page.Category())
+		n.Aliases = page.Aliases
+		n.Redirects = page.Redirects
+		n.setHits(page)
+		n.OutDegree = len(page.Links)
+		g.Nodes = append(g.Nodes, n)
+
+		for target, count := range page.Links {
+			g.Links = append(g.Links, newLink(page.Id, target, count))
+			inDegrees[target]++
 		}
+	}
+
+	for i := range g.Nodes {
+		g.Nodes[i].InDegree = inDegrees[g.Nodes[i].Id]
+	}
+	return &g
+}
+
+func (g *Graph) write(w io.Writer) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (g *Graph) save(path string) error {
+	buf := bytes.Buffer{}
+	if err := g.write(&buf); err != nil {
+		return err
+	}
+
+	return writeFile(path, buf.Bytes())
+}
+
+// WriteJson writes the page graph pg as D3 JSON to w.
+func WriteJson(w io.Writer, pg *graph.Graph) error {
+	return newGraph(pg).write(w)
+}
 
-		for target := range page.Links {
-			g.Links = append(g.Links, newLink(page.Id, graph.PageList[target].Id))
+// ReadJson reads the page graph from the D3 JSON written by WriteJson(), the
+// time windows are ignored.
+func ReadJson(r io.Reader) (*graph.Graph, error) {
+	g := Graph{}
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+
+	pages := make([]*graph.Page, 0, len(g.Nodes))
+	pageMap := make(map[int]*graph.Page)
+	for i := range g.Nodes {
+		page := g.Nodes[i].newPage()
+		pages = append(pages, page)
+		pageMap[page.Id] = page
+	}
+	for _, l := range g.Links {
+		page, ok := pageMap[l.Source]
+		if !ok {
+			return nil, fmt.Errorf("link %d -> %d is from a missing page", l.Source, l.Target)
+		}
+		// The links of the graphs written before the weights are seen once.
+		weight := l.Weight
+		if weight == 0 {
+			weight = 1
 		}
+		page.Links[l.Target] += weight
 	}
+	return graph.NewGraphOfPages(pages)
+}
+
+// GenerateJson writes the page graph pg as D3 JSON to the file at path.
+func GenerateJson(pg *graph.Graph, path string) error {
+	return newGraph(pg).save(path)
+}
 
-	if data, err := json.MarshalIndent(g, "", "  "); err == nil {
-		// fmt.Printf("%s\n", data)
+// GenerateTimelineJson writes the page graph pg pruned by opts like
+// GenerateJson, together with windows and the counts of each link in every
+// window, so the traffic can be replayed window by window. The pages of the
+// windows are matched to the pages of pg by name.
+func GenerateTimelineJson(pg *graph.Graph, windows []*Window, path string, opts *Options) error {
+	pg = opts.apply(pg)
+	g := newGraph(pg)
+	g.Windows = windows
 
-		err := ioutil.WriteFile("webgraph.json", data, os.ModePerm)
-		if err != nil {
-			panic(err)
+	linkMap := make(map[[2]int]*Link)
+	for i := range g.Links {
+		l := &g.Links[i]
+		l.Windows = make([]int, len(windows))
+		linkMap[[2]int{l.Source, l.Target}] = l
+	}
+
+	for i, w := range windows {
+		pages := w.Graph.Pages()
+		idMap := make(map[int]int)
+		for _, page := range pages {
+			if p := pg.GetPageByName(page.Name); p != nil {
+				idMap[page.Id] = p.Id
+			}
+		}
+
+		for _, page := range pages {
+			for target, count := range page.Links {
+				s, sOk := idMap[page.Id]
+				t, tOk := idMap[target]
+				if !sOk || !tOk {
+					continue
+				}
+				if l, ok := linkMap[[2]int{s, t}]; ok {
+					l.Windows[i] += count
+				}
+			}
 		}
 	}
+
+	return g.save(path)
+}
+
+// writeFile replaces the file at path with data through a rename, so the page
+// never loads a partly written graph while it is being updated.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
 }
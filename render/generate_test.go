@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+func loadGraph(t *testing.T, path string) *Graph {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := Graph{}
+	if err := json.Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	return &g
+}
+
+func TestGenerateJson(t *testing.T) {
+	t0 := time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC)
+
+	pg := graph.NewGraph()
+	pg.AddHit("/", t0, 200)
+	pg.AddLink("/", "/a/")
+	pg.AddLink("/", "/a/")
+	pg.AddLink("/", "/b/")
+	pg.AddLink("/a/", "/b/")
+	pg.AddHit("/a/", t0.Add(time.Hour), 200)
+
+	path := filepath.Join(t.TempDir(), "webgraph.json")
+	if err := GenerateJson(pg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadGraph(t, path)
+	degrees := [][2]int{{0, 2}, {1, 1}, {2, 0}}
+	for i, n := range g.Nodes {
+		if n.InDegree != degrees[i][0] || n.OutDegree != degrees[i][1] {
+			t.Errorf("node %s: InDegree, OutDegree = %d, %d, supposed to be %d, %d", n.Name, n.InDegree, n.OutDegree, degrees[i][0], degrees[i][1])
+		}
+	}
+	if a := g.Nodes[1]; a.Hits != 1 || a.FirstSeen == nil || !a.FirstSeen.Equal(t0.Add(time.Hour)) {
+		t.Errorf("node /a/ = %+v, supposed to have a hit at %v", a, t0.Add(time.Hour))
+	}
+	if b := g.Nodes[2]; b.Hits != 0 || b.FirstSeen != nil || b.LastSeen != nil {
+		t.Errorf("node /b/ = %+v, supposed to have no hits", b)
+	}
+
+	weights := map[[2]int]int{{0, 1}: 2, {0, 2}: 1, {1, 2}: 1}
+	for _, l := range g.Links {
+		if l.Weight != weights[[2]int{l.Source, l.Target}] {
+			t.Errorf("link %d -> %d: Weight = %d, supposed to be %d", l.Source, l.Target, l.Weight, weights[[2]int{l.Source, l.Target}])
+		}
+	}
+}
+
+func TestGenerateTimelineJson(t *testing.T) {
+	pg := graph.NewGraph()
+	pg.AddPage("/")
+	pg.AddLink("/", "/a/")
+	pg.AddLink("/", "/a/")
+	pg.AddLink("/a/", "/b/")
+
+	// The pages of the windows have other ids.
+	w1 := graph.NewGraph()
+	w1.AddLink("/a/", "/b/")
+	w1.AddLink("/", "/a/")
+	w2 := graph.NewGraph()
+	w2.AddLink("/", "/a/")
+
+	start := time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC)
+	windows := []*Window{
+		{Start: start, End: start.Add(time.Hour), Graph: w1},
+		{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Graph: w2},
+	}
+
+	path := filepath.Join(t.TempDir(), "webgraph.json")
+	if err := GenerateTimelineJson(pg, windows, path, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadGraph(t, path)
+	if len(g.Windows) != 2 || !g.Windows[1].Start.Equal(start.Add(time.Hour)) {
+		t.Errorf("Windows = %v, supposed to be 2 windows from %v", g.Windows, start)
+	}
+	res := map[[2]int][]int{{0, 1}: {1, 1}, {1, 2}: {1, 0}}
+	for _, l := range g.Links {
+		if !reflect.DeepEqual(l.Windows, res[[2]int{l.Source, l.Target}]) {
+			t.Errorf("link %d -> %d: Windows = %v, supposed to be %v", l.Source, l.Target, l.Windows, res[[2]int{l.Source, l.Target}])
+		}
+	}
+}
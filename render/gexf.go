@@ -0,0 +1,164 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+type gexfAttribute struct {
+	Id    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	Id        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	Id     string  `xml:"id,attr"`
+	Source string  `xml:"source,attr"`
+	Target string  `xml:"target,attr"`
+	Weight float64 `xml:"weight,attr"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfDoc struct {
+	XMLName xml.Name  `xml:"http://www.gexf.net/1.2draft gexf"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+// WriteGexf writes the page graph pg in GEXF 1.2 to w, for Gephi. The nodes
+// have the attributes of nodeAttrs and the edges have their weights.
+func WriteGexf(w io.Writer, pg *graph.Graph) error {
+	doc := gexfDoc{}
+	doc.Version = "1.2"
+	doc.Graph.DefaultEdgeType = "directed"
+
+	attrs := gexfAttributes{}
+	attrs.Class = "node"
+	for _, attr := range nodeAttrs {
+		typ := "string"
+		if attr.integer {
+			typ = "integer"
+		}
+		attrs.Attributes = append(attrs.Attributes, gexfAttribute{attr.name, attr.name, typ})
+	}
+	doc.Graph.Attributes = []gexfAttributes{attrs}
+
+	for _, page := range pg.Pages() {
+		n := gexfNode{}
+		n.Id = strconv.Itoa(page.Id)
+		n.Label = page.Name
+		for _, attr := range nodeAttrs {
+			if value := attr.get(page); value != "" {
+				n.AttValues = append(n.AttValues, gexfAttValue{attr.name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+
+		for _, target := range getSortedTargets(page) {
+			e := gexfEdge{}
+			e.Id = strconv.Itoa(len(doc.Graph.Edges))
+			e.Source = n.Id
+			e.Target = strconv.Itoa(target)
+			e.Weight = float64(page.Links[target])
+			doc.Graph.Edges = append(doc.Graph.Edges, e)
+		}
+	}
+
+	return writeXml(w, doc)
+}
+
+// ReadGexf reads the page graph from the GEXF written by WriteGexf(). The nodes
+// of the other tools are named by their "pattern" attributes, or else by their
+// labels.
+func ReadGexf(r io.Reader) (*graph.Graph, error) {
+	doc := gexfDoc{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	titles := make(map[string]string)
+	for _, attrs := range doc.Graph.Attributes {
+		if attrs.Class == "node" {
+			for _, attr := range attrs.Attributes {
+				titles[attr.Id] = attr.Title
+			}
+		}
+	}
+	nodes := make([]string, 0, len(doc.Graph.Nodes))
+	for _, n := range doc.Graph.Nodes {
+		nodes = append(nodes, n.Id)
+	}
+	ids := getNodeIds(nodes, "")
+
+	pages := make([]*graph.Page, 0, len(doc.Graph.Nodes))
+	pageMap := make(map[string]*graph.Page)
+	for _, n := range doc.Graph.Nodes {
+		page := &graph.Page{Id: ids[n.Id], Name: n.Label, Links: make(map[int]int)}
+		if page.Name == "" {
+			page.Name = n.Id
+		}
+		for _, v := range n.AttValues {
+			if err := setNodeAttr(page, titles[v.For], v.Value); err != nil {
+				return nil, err
+			}
+		}
+		pages = append(pages, page)
+		pageMap[n.Id] = page
+	}
+
+	for _, e := range doc.Graph.Edges {
+		source, sOk := pageMap[e.Source]
+		target, tOk := pageMap[e.Target]
+		if !sOk || !tOk {
+			return nil, fmt.Errorf("edge %s -> %s is between missing nodes", e.Source, e.Target)
+		}
+		// The weight is 1 if omitted.
+		weight := int(math.Round(e.Weight))
+		if weight == 0 {
+			weight = 1
+		}
+		source.Links[target.Id] += weight
+	}
+	return graph.NewGraphOfPages(pages)
+}
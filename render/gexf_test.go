@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteGexf(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := WriteGexf(&b, newTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := gexfDoc{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "1.2" || len(doc.Graph.Attributes) != 1 || len(doc.Graph.Nodes) != 5 || len(doc.Graph.Edges) != 4 {
+		t.Fatalf("WriteGexf() = %s, supposed to have the node attributes, 5 nodes and 4 edges", b.String())
+	}
+
+	home := gexfNode{"0", "/", []gexfAttValue{{"pattern", "/"}, {"aliases", `["/home/"]`}, {"category", "home"}, {"hits", "0"}}}
+	if !reflect.DeepEqual(doc.Graph.Nodes[0], home) {
+		t.Errorf("node 0 = %+v, supposed to be %+v", doc.Graph.Nodes[0], home)
+	}
+	if e := doc.Graph.Edges[0]; e != (gexfEdge{"0", "0", "1", 2}) {
+		t.Errorf("edge 0 = %+v, supposed to be 0 -> 1 of weight 2", e)
+	}
+}
+
+func TestReadGexf(t *testing.T) {
+	// A GEXF of another tool, the nodes are named by their labels.
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">
+  <graph defaultedgetype="directed">
+    <attributes class="node">
+      <attribute id="0" title="hits" type="integer"/>
+    </attributes>
+    <nodes>
+      <node id="5" label="/"><attvalues><attvalue for="0" value="3"/></attvalues></node>
+      <node id="7" label="/about/"/>
+    </nodes>
+    <edges>
+      <edge id="0" source="5" target="7"/>
+    </edges>
+  </graph>
+</gexf>`
+	pg, err := ReadGexf(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := pg.GetPageByName("/")
+	if home == nil || home.Id != 5 || home.Hits != 3 || !reflect.DeepEqual(home.Links, map[int]int{7: 1}) {
+		t.Errorf("/ = %+v, supposed to be the page 5 of 3 hits linking to the page 7 once", home)
+	}
+
+	bad := strings.Replace(data, `value="3"`, `value="x"`, 1)
+	if _, err := ReadGexf(strings.NewReader(bad)); err == nil {
+		t.Errorf("ReadGexf() of a bad hits succeeded, supposed to fail")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+type graphmlKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Id     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlDoc struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+// writeXml writes the XML document doc with its header to w.
+func writeXml(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteGraphml writes the page graph pg in GraphML to w, for yEd or Gephi. The
+// nodes have the attributes of nodeAttrs and the edges have their weights.
+func WriteGraphml(w io.Writer, pg *graph.Graph) error {
+	doc := graphmlDoc{}
+	for _, attr := range nodeAttrs {
+		typ := "string"
+		if attr.integer {
+			typ = "int"
+		}
+		doc.Keys = append(doc.Keys, graphmlKey{attr.name, "node", attr.name, typ})
+	}
+	doc.Keys = append(doc.Keys, graphmlKey{"weight", "edge", "weight", "double"})
+
+	doc.Graph.Id = "webgraph"
+	doc.Graph.EdgeDefault = "directed"
+	for _, page := range pg.Pages() {
+		n := graphmlNode{}
+		n.Id = fmt.Sprintf("n%d", page.Id)
+		for _, attr := range nodeAttrs {
+			if value := attr.get(page); value != "" {
+				n.Data = append(n.Data, graphmlData{attr.name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+
+		for _, target := range getSortedTargets(page) {
+			e := graphmlEdge{}
+			e.Id = fmt.Sprintf("e%d", len(doc.Graph.Edges))
+			e.Source = n.Id
+			e.Target = fmt.Sprintf("n%d", target)
+			e.Data = []graphmlData{{"weight", strconv.Itoa(page.Links[target])}}
+			doc.Graph.Edges = append(doc.Graph.Edges, e)
+		}
+	}
+
+	return writeXml(w, doc)
+}
+
+// ReadGraphml reads the page graph from the GraphML written by WriteGraphml().
+// The nodes of the other tools are named by their "pattern" attributes, or
+// else by their ids.
+func ReadGraphml(r io.Reader) (*graph.Graph, error) {
+	doc := graphmlDoc{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	for _, k := range doc.Keys {
+		keys[k.Id] = k.Name
+	}
+	nodes := make([]string, 0, len(doc.Graph.Nodes))
+	for _, n := range doc.Graph.Nodes {
+		nodes = append(nodes, n.Id)
+	}
+	ids := getNodeIds(nodes, "n")
+
+	pages := make([]*graph.Page, 0, len(doc.Graph.Nodes))
+	pageMap := make(map[string]*graph.Page)
+	for _, n := range doc.Graph.Nodes {
+		page := &graph.Page{Id: ids[n.Id], Name: n.Id, Links: make(map[int]int)}
+		for _, d := range n.Data {
+			if err := setNodeAttr(page, keys[d.Key], d.Value); err != nil {
+				return nil, err
+			}
+		}
+		pages = append(pages, page)
+		pageMap[n.Id] = page
+	}
+
+	for _, e := range doc.Graph.Edges {
+		source, sOk := pageMap[e.Source]
+		target, tOk := pageMap[e.Target]
+		if !sOk || !tOk {
+			return nil, fmt.Errorf("edge %s -> %s is between missing nodes", e.Source, e.Target)
+		}
+		weight := 1
+		for _, d := range e.Data {
+			if keys[d.Key] == "weight" {
+				w, err := strconv.ParseFloat(d.Value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("edge %s -> %s: weight: %v", e.Source, e.Target, err)
+				}
+				weight = int(math.Round(w))
+			}
+		}
+		source.Links[target.Id] += weight
+	}
+	return graph.NewGraphOfPages(pages)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteGraphml(t *testing.T) {
+	pg := newTestGraph()
+	pg.AddHit("/author/*/", time.Date(2000, 10, 10, 13, 0, 0, 0, time.UTC), 404)
+	pg.AddHit("/author/*/", time.Date(2000, 10, 10, 14, 0, 0, 0, time.UTC), 200)
+	pg.AddHit("/author/*/", time.Date(2000, 10, 10, 15, 0, 0, 0, time.UTC), 200)
+
+	b := bytes.Buffer{}
+	if err := WriteGraphml(&b, pg); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := graphmlDoc{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Keys) != len(nodeAttrs)+1 || len(doc.Graph.Nodes) != 5 || len(doc.Graph.Edges) != 4 {
+		t.Fatalf("WriteGraphml() = %s, supposed to have %d keys, 5 nodes and 4 edges", b.String(), len(nodeAttrs)+1)
+	}
+
+	home := []graphmlData{{"pattern", "/"}, {"aliases", `["/home/"]`}, {"category", "home"}, {"hits", "0"}}
+	if !reflect.DeepEqual(doc.Graph.Nodes[0].Data, home) {
+		t.Errorf("node / = %v, supposed to be %v", doc.Graph.Nodes[0].Data, home)
+	}
+	author := []graphmlData{{"pattern", "/author/*/"}, {"category", "page"}, {"hits", "3"}, {"status", "200"}, {"statuses", "200:2 404:1"},
+		{"firstSeen", "2000-10-10T13:00:00Z"}, {"lastSeen", "2000-10-10T15:00:00Z"}}
+	if !reflect.DeepEqual(doc.Graph.Nodes[1].Data, author) {
+		t.Errorf("node /author/*/ = %v, supposed to be %v", doc.Graph.Nodes[1].Data, author)
+	}
+	if e := doc.Graph.Edges[0]; e.Source != "n0" || e.Target != "n1" || !reflect.DeepEqual(e.Data, []graphmlData{{"weight", "2"}}) {
+		t.Errorf("edge #0 = %+v, supposed to be n0 -> n1 of weight 2", e)
+	}
+}
+
+func TestReadGraphml(t *testing.T) {
+	// A GraphML of another tool, the nodes are numbered in their order.
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="d0" for="node" attr.name="pattern" attr.type="string"/>
+  <key id="d1" for="edge" attr.name="weight" attr.type="double"/>
+  <graph edgedefault="directed">
+    <node id="home"><data key="d0">/</data></node>
+    <node id="about"/>
+    <edge source="home" target="about"><data key="d1">2.0</data></edge>
+    <edge source="about" target="home"/>
+  </graph>
+</graphml>`
+	pg, err := ReadGraphml(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, about := pg.GetPage(0), pg.GetPage(1)
+	if home == nil || home.Name != "/" || !reflect.DeepEqual(home.Links, map[int]int{1: 2}) {
+		t.Errorf("page 0 = %+v, supposed to be / linking to the page 1 twice", home)
+	}
+	if about == nil || about.Name != "about" || !reflect.DeepEqual(about.Links, map[int]int{0: 1}) {
+		t.Errorf("page 1 = %+v, supposed to be about linking to the page 0 once", about)
+	}
+
+	bad := strings.Replace(data, `target="home"`, `target="missing"`, 1)
+	if _, err := ReadGraphml(strings.NewReader(bad)); err == nil {
+		t.Errorf("ReadGraphml() of an edge to a missing node succeeded, supposed to fail")
+	}
+}
@@ -17,11 +17,20 @@ package render
 type Link struct {
 	Source int `json:"source"`
 	Target int `json:"target"`
+	// Weight is the number of the times the link is found or followed.
+	Weight int `json:"weight"`
+	// Windows are the counts of the link in the time windows of the graph.
+	Windows []int `json:"windows,omitempty"`
+	// Diff is the status of the link in a diff graph like "added", and
+	// OldWeight is its weight in the old graph.
+	Diff      string `json:"diff,omitempty"`
+	OldWeight int    `json:"oldWeight,omitempty"`
 }
 
-func newLink(source int, target int) Link {
+func newLink(source int, target int, weight int) Link {
 	l := Link{}
 	l.Source = source
 	l.Target = target
+	l.Weight = weight
 	return l
 }
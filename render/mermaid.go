@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+// mermaidQuote quotes the label s, the quotes in it are escaped as entities.
+func mermaidQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
+
+// WriteMermaid writes the page graph pg as a Mermaid flowchart to w, which can
+// be embedded in Markdown as a "mermaid" code block. The home page is drawn as
+// a stadium and the links are labeled with their weights.
+func WriteMermaid(w io.Writer, pg *graph.Graph) error {
+	pages := pg.Pages()
+
+	b := bytes.Buffer{}
+	b.WriteString("flowchart LR\n")
+	for _, page := range pages {
+		if page.Name == "/" {
+			fmt.Fprintf(&b, "    n%d([%s])\n", page.Id, mermaidQuote(page.Name))
+		} else {
+			fmt.Fprintf(&b, "    n%d[%s]\n", page.Id, mermaidQuote(page.Name))
+		}
+	}
+
+	for _, page := range pages {
+		for _, target := range getSortedTargets(page) {
+			if count := page.Links[target]; count > 1 {
+				fmt.Fprintf(&b, "    n%d -->|%d| n%d\n", page.Id, count, target)
+			} else {
+				fmt.Fprintf(&b, "    n%d --> n%d\n", page.Id, target)
+			}
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -12,6 +12,31 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package target
+package render
 
-var Url = "https://example.com/"
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMermaid(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := WriteMermaid(&b, newTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := `flowchart LR
+    n0(["/"])
+    n1["/author/*/"]
+    n2["/tag/*/"]
+    n3["/author/*/books/"]
+    n4["/about#quot;/"]
+    n0 -->|2| n1
+    n0 --> n2
+    n1 --> n3
+    n2 --> n4
+`
+	if b.String() != res {
+		t.Errorf("WriteMermaid() = %s, supposed to be %s", b.String(), res)
+	}
+}
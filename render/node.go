@@ -14,10 +14,40 @@
 
 package render
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
 type Node struct {
 	Id       int    `json:"id"`
 	Name     string `json:"name"`
 	Category string `json:"category"`
+	// Aliases are the other names of the page, like the paths redirected to
+	// it, and Redirects are the redirections between them.
+	Aliases   []string         `json:"aliases,omitempty"`
+	Redirects []graph.Redirect `json:"redirects,omitempty"`
+
+	// Hits is the number of the requests to the page, FirstSeen and
+	// LastSeen are only set if it has any.
+	Hits      int        `json:"hits"`
+	FirstSeen *time.Time `json:"firstSeen,omitempty"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+	// Statuses counts the hits by their HTTP status codes.
+	Statuses map[int]int `json:"statuses,omitempty"`
+	// InDegree and OutDegree are the numbers of the pages linking to and
+	// linked from the page.
+	InDegree  int `json:"inDegree"`
+	OutDegree int `json:"outDegree"`
+	// Diff is the status of the page in a diff graph like "added", and
+	// OldName is its name in the old graph if it is "changed".
+	Diff    string `json:"diff,omitempty"`
+	OldName string `json:"oldName,omitempty"`
 }
 
 func newNode(id int, name string, category string) Node {
@@ -27,3 +57,157 @@ func newNode(id int, name string, category string) Node {
 	n.Category = category
 	return n
 }
+
+func (n *Node) setHits(page *graph.Page) {
+	n.Hits = page.Hits
+	// The times are unknown for the graphs read from CSV.
+	if page.Hits != 0 && !page.FirstSeen.IsZero() {
+		n.FirstSeen = &page.FirstSeen
+		n.LastSeen = &page.LastSeen
+	}
+	n.Statuses = page.Statuses
+}
+
+// newPage creates the page of the node without its links.
+func (n *Node) newPage() *graph.Page {
+	page := graph.Page{}
+	page.Id = n.Id
+	page.Name = n.Name
+	page.Aliases = n.Aliases
+	page.Redirects = n.Redirects
+	page.Links = make(map[int]int)
+	page.Hits = n.Hits
+	if n.FirstSeen != nil {
+		page.FirstSeen = *n.FirstSeen
+	}
+	if n.LastSeen != nil {
+		page.LastSeen = *n.LastSeen
+	}
+	page.Statuses = n.Statuses
+	return &page
+}
+
+// getStatus gets the most frequent HTTP status of page, or 0 if unknown.
+func getStatus(page *graph.Page) int {
+	status, max := 0, 0
+	for s, count := range page.Statuses {
+		if count > max || count == max && s < status {
+			status, max = s, count
+		}
+	}
+	return status
+}
+
+// getStatuses gets the HTTP statuses of page with their counts, like
+// "200:5 404:1".
+func getStatuses(page *graph.Page) string {
+	statuses := make([]int, 0, len(page.Statuses))
+	for status := range page.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	items := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		items = append(items, fmt.Sprintf("%d:%d", status, page.Statuses[status]))
+	}
+	return strings.Join(items, " ")
+}
+
+// parseStatuses parses the HTTP statuses formatted by getStatuses().
+func parseStatuses(s string) (map[int]int, error) {
+	statuses := make(map[int]int)
+	for _, item := range strings.Fields(s) {
+		var status, count int
+		if _, err := fmt.Sscanf(item, "%d:%d", &status, &count); err != nil {
+			return nil, fmt.Errorf("%q is not like 200:5", item)
+		}
+		statuses[status] += count
+	}
+	return statuses, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// nodeAttr is an attribute of the nodes in the GraphML and GEXF outputs, its
+// value is empty if unknown. set sets the attribute of a page read from the
+// outputs, it is nil for the attributes derived from the others.
+type nodeAttr struct {
+	name    string
+	integer bool
+	get     func(page *graph.Page) string
+	set     func(page *graph.Page, value string) error
+}
+
+var nodeAttrs = []nodeAttr{
+	{"pattern", false, func(page *graph.Page) string { return page.Name }, func(page *graph.Page, value string) error {
+		page.Name = value
+		return nil
+	}},
+	{"aliases", false, func(page *graph.Page) string { return graph.FormatAliases(page.Aliases) }, func(page *graph.Page, value string) (err error) {
+		page.Aliases, err = graph.ParseAliases(value)
+		return err
+	}},
+	{"category", false, (*graph.Page).Category, nil},
+	{"hits", true, func(page *graph.Page) string { return strconv.Itoa(page.Hits) }, func(page *graph.Page, value string) (err error) {
+		page.Hits, err = strconv.Atoi(value)
+		return err
+	}},
+	{"status", true, func(page *graph.Page) string {
+		if status := getStatus(page); status != 0 {
+			return strconv.Itoa(status)
+		}
+		return ""
+	}, nil},
+	{"statuses", false, getStatuses, func(page *graph.Page, value string) (err error) {
+		page.Statuses, err = parseStatuses(value)
+		return err
+	}},
+	{"firstSeen", false, func(page *graph.Page) string { return formatTime(page.FirstSeen) }, func(page *graph.Page, value string) (err error) {
+		page.FirstSeen, err = time.Parse(time.RFC3339, value)
+		return err
+	}},
+	{"lastSeen", false, func(page *graph.Page) string { return formatTime(page.LastSeen) }, func(page *graph.Page, value string) (err error) {
+		page.LastSeen, err = time.Parse(time.RFC3339, value)
+		return err
+	}},
+}
+
+// setNodeAttr sets the attribute name of page to value, the unknown and
+// derived attributes are ignored.
+func setNodeAttr(page *graph.Page, name string, value string) error {
+	for _, attr := range nodeAttrs {
+		if attr.name == name && attr.set != nil {
+			if err := attr.set(page, value); err != nil {
+				return fmt.Errorf("page %d: %s: %v", page.Id, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getNodeIds maps the node ids of a graph read from the other formats to the
+// page ids. The ids like "n1" with prefix "n" are kept, or else the nodes are
+// numbered in their order.
+func getNodeIds(nodes []string, prefix string) map[string]int {
+	ids := make(map[string]int)
+	used := make(map[int]bool)
+	for _, node := range nodes {
+		id, err := strconv.Atoi(strings.TrimPrefix(node, prefix))
+		if err != nil || id < 0 || used[id] || !strings.HasPrefix(node, prefix) {
+			ids = make(map[string]int)
+			for i, node := range nodes {
+				ids[node] = i
+			}
+			return ids
+		}
+		ids[node] = id
+		used[id] = true
+	}
+	return ids
+}
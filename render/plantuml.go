@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hsluoyz/logdance/graph"
+)
+
+// WritePlantuml writes the page graph pg as a PlantUML diagram of rectangles
+// to w. The links are labeled with their weights.
+func WritePlantuml(w io.Writer, pg *graph.Graph) error {
+	pages := pg.Pages()
+
+	b := bytes.Buffer{}
+	b.WriteString("@startuml\n")
+	b.WriteString("left to right direction\n")
+	for _, page := range pages {
+		// PlantUML can not escape the quotes in the names.
+		name := strings.Replace(page.Name, `"`, "'", -1)
+		if page.Name == "/" {
+			fmt.Fprintf(&b, "rectangle \"%s\" as n%d #lightblue\n", name, page.Id)
+		} else {
+			fmt.Fprintf(&b, "rectangle \"%s\" as n%d\n", name, page.Id)
+		}
+	}
+
+	for _, page := range pages {
+		for _, target := range getSortedTargets(page) {
+			if count := page.Links[target]; count > 1 {
+				fmt.Fprintf(&b, "n%d --> n%d : %d\n", page.Id, target, count)
+			} else {
+				fmt.Fprintf(&b, "n%d --> n%d\n", page.Id, target)
+			}
+		}
+	}
+	b.WriteString("@enduml\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWritePlantuml(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := WritePlantuml(&b, newTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := `@startuml
+left to right direction
+rectangle "/" as n0 #lightblue
+rectangle "/author/*/" as n1
+rectangle "/tag/*/" as n2
+rectangle "/author/*/books/" as n3
+rectangle "/about'/" as n4
+n0 --> n1 : 2
+n0 --> n2
+n1 --> n3
+n2 --> n4
+@enduml
+`
+	if b.String() != res {
+		t.Errorf("WritePlantuml() = %s, supposed to be %s", b.String(), res)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"embed"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/hsluoyz/logdance/render"
+	"github.com/hsluoyz/logdance/server"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// assets are the files of the visualisation, so one binary serves it all.
+//
+//go:embed index.html d3.v4.js
+var assets embed.FS
+
+// startServer serves the visualisation at addr in the background.
+func startServer(addr string) (*server.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := server.NewServer(assets)
+	go func() {
+		if err := s.Serve(l); err != nil {
+			fmt.Fprintf(os.Stderr, "logdance: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+	if verbosity >= 1 {
+		fmt.Printf("Serving at http://%s/\n", getServerHost(l.Addr()))
+	}
+	return s, nil
+}
+
+// getServerHost gets the host to browse the server at addr, e.g.,
+// "[::]:8080" -> "localhost:8080".
+func getServerHost(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", ":8080", "address to serve the visualisation at")
+	inFormat := fs.String("in-format", "", fmt.Sprintf("format of the graph, one of %v, by the extension of the file if empty", render.GetReadableFormatNames()))
+	logFile := fs.String("log", "page.log", "log file, empty to disable logging")
+	fs.IntVar(&verbosity, "v", 1, "verbosity: 0 quiet, 1 the address")
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := "webgraph.json"
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+
+	if err := util.SetLogFile(*logFile); err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	// The graphs of the other formats are converted once, only the JSON is
+	// reloaded when it changes.
+	s := server.NewServer(assets)
+	format := *inFormat
+	if format == "" {
+		format = render.GetFormatByPath(path).Name
+	}
+	if format == "json" {
+		if err := s.SetFile(path); err != nil {
+			return err
+		}
+	} else {
+		g, err := render.Load(path, format)
+		if err != nil {
+			return err
+		}
+		s.SetGraph(g)
+	}
+	if verbosity >= 1 {
+		fmt.Printf("Serving %s at http://%s/\n", path, getServerHost(l.Addr()))
+	}
+	return s.Serve(l)
+}
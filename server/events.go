@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// eventBuffer is the number of the events a client can lag behind, a client
+// lagging more is disconnected and reloads the graph when it reconnects.
+const eventBuffer = 256
+
+// setLive streams the events of pg, nil stops streaming. The current clients
+// are disconnected, so they reload the graph. It is called with s.mu held.
+func (s *Server) setLive(pg *graph.Graph) {
+	// The listener of pg is added before pg goes live so no event is missed,
+	// and the graph is not called with clientsMu held, which its listener
+	// locks.
+	listener := 0
+	if pg != nil && pg != s.live {
+		listener = pg.AddListener(func(e graph.Event) {
+			s.broadcast(pg, e)
+		})
+	}
+
+	s.clientsMu.Lock()
+	old := s.live
+	if old == pg {
+		s.clientsMu.Unlock()
+		return
+	}
+	s.live = pg
+	for ch := range s.clients {
+		close(ch)
+		delete(s.clients, ch)
+	}
+	s.clientsMu.Unlock()
+
+	if old != nil {
+		old.RemoveListener(s.listener)
+	}
+	s.listener = listener
+}
+
+// broadcast sends the event e of pg to the clients without blocking the graph.
+func (s *Server) broadcast(pg *graph.Graph, e graph.Event) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if s.live != pg {
+		return
+	}
+	for ch := range s.clients {
+		select {
+		case ch <- e:
+		default:
+			util.LogPrint("Drop the lagging event client")
+			close(ch)
+			delete(s.clients, ch)
+		}
+	}
+}
+
+// subscribe returns a new channel of the events, or nil if no live graph is
+// served.
+func (s *Server) subscribe() chan graph.Event {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if s.live == nil {
+		return nil
+	}
+	ch := make(chan graph.Event, eventBuffer)
+	s.clients[ch] = true
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan graph.Event) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if s.clients[ch] {
+		close(ch)
+		delete(s.clients, ch)
+	}
+}
+
+// serveEvents streams the events of the live graph as the server-sent events,
+// each one is a graph.Event in JSON.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	if ch == nil {
+		http.Error(w, "no live graph is served", http.StatusNotFound)
+		return
+	}
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			// Flush the events received meanwhile at once.
+			if len(ch) == 0 {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/render"
+)
+
+func readEvent(t *testing.T, r *bufio.Reader) graph.Event {
+	t.Helper()
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("event line = %q, supposed to start with \"data: \"", line)
+	}
+	e := graph.Event{}
+	if err := json.Unmarshal([]byte(line[len("data: "):]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := r.ReadString('\n'); line != "\n" {
+		t.Fatalf("event end = %q, supposed to be an empty line", line)
+	}
+	return e
+}
+
+func TestServerEvents(t *testing.T) {
+	s := NewServer(testAssets)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	pg := graph.NewGraph()
+	pg.AddPage("/")
+	s.SetGraph(pg)
+	s.SetGraph(pg)
+
+	resp, err := http.Get(ts.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, supposed to be text/event-stream", ct)
+	}
+
+	pg.AddLink("/", "/a/")
+	pg.AddLink("/", "/a/")
+	r := bufio.NewReader(resp.Body)
+	events := []graph.Event{readEvent(t, r), readEvent(t, r), readEvent(t, r)}
+	want := []graph.Event{
+		{Type: graph.EventPage, Id: 1, Name: "/a/"},
+		{Type: graph.EventLink, Source: 0, Target: 1, Weight: 1},
+		{Type: graph.EventLink, Source: 0, Target: 1, Weight: 2},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, supposed to be %+v", events, want)
+	}
+
+	// A graph file has no events, and the stream of the old graph ends.
+	path := filepath.Join(t.TempDir(), "webgraph.json")
+	if err := render.GenerateJson(pg, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Errorf("the stream of the old graph is still open")
+	}
+	testGet(t, s.Handler(), "/api/events", http.StatusNotFound)
+}
+
+func TestServerEventsLagging(t *testing.T) {
+	s := NewServer(testAssets)
+	pg := graph.NewGraph()
+	s.SetGraph(pg)
+
+	ch := s.subscribe()
+	for i := 0; i <= eventBuffer; i++ {
+		pg.AddPage(strings.Repeat("/a", i+1))
+	}
+	count := 0
+	for range ch {
+		count++
+	}
+	if count != eventBuffer {
+		t.Errorf("%d events are received, supposed to be %d before the lagging client is dropped", count, eventBuffer)
+	}
+	s.unsubscribe(ch)
+}
+
+func TestServerEventsSwitch(t *testing.T) {
+	s := NewServer(testAssets)
+	a := graph.NewGraph()
+	b := graph.NewGraph()
+	s.SetGraph(a)
+	s.SetGraph(b)
+	s.SetGraph(a)
+
+	// Each event of a is sent once, and b is no longer listened to.
+	ch := s.subscribe()
+	a.AddPage("/")
+	b.AddPage("/")
+	s.unsubscribe(ch)
+	events := []graph.Event{}
+	for e := range ch {
+		events = append(events, e)
+	}
+	if want := []graph.Event{{Type: graph.EventPage, Id: 0, Name: "/"}}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, supposed to be %+v", events, want)
+	}
+}
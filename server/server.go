@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/render"
+	"github.com/hsluoyz/logdance/util"
+)
+
+// Server serves the visualisation of a page graph: the files of the page like
+// index.html, and the graph JSON at /webgraph.json and /api/graph. The graph
+// is either a live graph rendered on each request, or a graph JSON file
+// reloaded when it changes. The changes of a live graph are also streamed at
+// /api/events.
+type Server struct {
+	assets fs.FS
+
+	mu      sync.Mutex
+	graph   *graph.Graph
+	path    string
+	modTime time.Time
+	data    []byte
+
+	// clientsMu guards the event streams, it is locked by the listener of the
+	// live graph, so the graph must not be called while it is held.
+	clientsMu sync.Mutex
+	live      *graph.Graph
+	clients   map[chan graph.Event]bool
+	// listener is the id of the listener of the live graph, it is guarded
+	// by mu.
+	listener int
+}
+
+// NewServer creates a server of the page files in assets.
+func NewServer(assets fs.FS) *Server {
+	s := Server{}
+	s.assets = assets
+	s.clients = make(map[chan graph.Event]bool)
+	return &s
+}
+
+// SetGraph serves the live graph pg, which may be still being built.
+func (s *Server) SetGraph(pg *graph.Graph) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.graph = pg
+	s.path = ""
+	s.data = nil
+	s.setLive(pg)
+}
+
+// SetFile serves the graph JSON file at path, it is reloaded when it changes.
+func (s *Server) SetFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.graph = nil
+	s.path = path
+	s.modTime = time.Time{}
+	s.setLive(nil)
+	_, err := s.loadFile()
+	return err
+}
+
+// loadFile reloads the file if it has changed since the last load.
+func (s *Server) loadFile() ([]byte, error) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if s.data != nil && fi.ModTime().Equal(s.modTime) {
+		return s.data, nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	util.LogPrint("Load graph: ", s.path)
+	s.data = data
+	s.modTime = fi.ModTime()
+	return data, nil
+}
+
+// getJson gets the graph JSON to serve.
+func (s *Server) getJson() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path != "" {
+		return s.loadFile()
+	}
+
+	pg := s.graph
+	if pg == nil {
+		pg = graph.NewGraph()
+	}
+	buf := bytes.Buffer{}
+	if err := render.WriteJson(&buf, pg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Server) serveGraph(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getJson()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webgraph.json", s.serveGraph)
+	mux.HandleFunc("/api/graph", s.serveGraph)
+	mux.HandleFunc("/api/events", s.serveEvents)
+	mux.Handle("/", http.FileServer(http.FS(s.assets)))
+	return mux
+}
+
+// Serve serves the HTTP requests from l.
+func (s *Server) Serve(l net.Listener) error {
+	return http.Serve(l, s.Handler())
+}
+
+// ListenAndServe serves the HTTP requests at addr like ":8080".
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
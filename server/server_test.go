@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hsluoyz/logdance/graph"
+	"github.com/hsluoyz/logdance/render"
+)
+
+var testAssets = fstest.MapFS{
+	"index.html": {Data: []byte("<svg></svg>")},
+	"d3.v4.js":   {Data: []byte("// d3")},
+}
+
+func testGet(t *testing.T, h http.Handler, path string, code int) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != code {
+		t.Errorf("GET %s = %d, supposed to be %d", path, rec.Code, code)
+	}
+	return rec.Body.String()
+}
+
+func testGetGraph(t *testing.T, h http.Handler, path string) *render.Graph {
+	t.Helper()
+
+	g := render.Graph{}
+	if err := json.Unmarshal([]byte(testGet(t, h, path, http.StatusOK)), &g); err != nil {
+		t.Fatal(err)
+	}
+	return &g
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(testAssets)
+	h := s.Handler()
+
+	if body := testGet(t, h, "/", http.StatusOK); body != "<svg></svg>" {
+		t.Errorf("GET / = %q, supposed to be index.html", body)
+	}
+	if body := testGet(t, h, "/d3.v4.js", http.StatusOK); body != "// d3" {
+		t.Errorf("GET /d3.v4.js = %q, supposed to be d3.v4.js", body)
+	}
+	testGet(t, h, "/missing.js", http.StatusNotFound)
+
+	// The live graph is rendered on each request.
+	pg := graph.NewGraph()
+	pg.AddPage("/")
+	s.SetGraph(pg)
+	if g := testGetGraph(t, h, "/webgraph.json"); len(g.Nodes) != 1 {
+		t.Errorf("GET /webgraph.json = %+v, supposed to be 1 node", g)
+	}
+	pg.AddLink("/", "/a/")
+	if g := testGetGraph(t, h, "/api/graph"); len(g.Nodes) != 2 || len(g.Links) != 1 {
+		t.Errorf("GET /api/graph = %+v, supposed to be 2 nodes and 1 link", g)
+	}
+}
+
+func TestServerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webgraph.json")
+	pg := graph.NewGraph()
+	pg.AddPage("/")
+	if err := render.GenerateJson(pg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(testAssets)
+	if err := s.SetFile(path); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+	if g := testGetGraph(t, h, "/webgraph.json"); len(g.Nodes) != 1 {
+		t.Errorf("GET /webgraph.json = %+v, supposed to be 1 node", g)
+	}
+
+	// The file is reloaded when it changes.
+	pg.AddLink("/", "/a/")
+	if err := render.GenerateJson(pg, path); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if g := testGetGraph(t, h, "/api/graph"); len(g.Nodes) != 2 {
+		t.Errorf("GET /api/graph = %+v, supposed to be 2 nodes after the change", g)
+	}
+
+	os.Remove(path)
+	testGet(t, h, "/api/graph", http.StatusInternalServerError)
+	if err := s.SetFile(path); err == nil {
+		t.Errorf("SetFile() of a missing file succeeded, supposed to fail")
+	}
+}
@@ -22,18 +22,22 @@ import (
 // EnableLog controls whether to print log to console.
 var EnableLog = true
 
-func init() {
-	//create your file with desired read/write permissions
-	f, err := os.OpenFile("page.log", os.O_CREATE | os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatal(err)
+// SetLogFile redirects the log to the file at path. An empty path disables
+// logging altogether.
+func SetLogFile(path string) error {
+	if path == "" {
+		EnableLog = false
+		return nil
 	}
 
-	//defer to close when you're done with it, not because you think it's idiomatic!
-	// defer f.Close()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
 
-	//set output of logs to f
 	log.SetOutput(f)
+	EnableLog = true
+	return nil
 }
 
 // LogPrint prints the log.
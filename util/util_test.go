@@ -19,3 +19,19 @@ import "testing"
 func TestLog(t *testing.T) {
 	LogPrint("Test")
 }
+
+func TestSetLogFile(t *testing.T) {
+	path := t.TempDir() + "/page.log"
+	if err := SetLogFile(path); err != nil {
+		t.Fatal(err)
+	}
+	LogPrint("Test")
+
+	if err := SetLogFile(""); err != nil {
+		t.Fatal(err)
+	}
+	if EnableLog {
+		t.Error("SetLogFile(\"\") should disable the log")
+	}
+	EnableLog = true
+}